@@ -0,0 +1,88 @@
+// Command offimport loads the products of selected countries from an Open
+// Food Facts data export into the offline product database, which is asked
+// before any network lookup:
+//
+//	offimport -countries germany,austria openfoodfacts-products.jsonl.gz
+//
+// Both the CSV export (en.openfoodfacts.org.products.csv.gz) and the JSONL
+// export (openfoodfacts-products.jsonl.gz) are supported, compressed or not;
+// see https://world.openfoodfacts.org/data. The file is streamed, so the
+// multi-gigabyte exports do not need to fit into memory. Every import
+// replaces the previous one. The database is configured with the same
+// environment variables as the server; other settings of the server, such as
+// those of the lookup providers, are not read.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"foodinventory/internal/config"
+	"foodinventory/internal/db"
+	"foodinventory/internal/offdump"
+	"foodinventory/internal/service"
+)
+
+func main() {
+	countriesFlag := flag.String("countries", "",
+		"comma-separated countries whose products are imported, as English names or\n"+
+			"Open Food Facts tags, e.g. germany,united-kingdom or en:france")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: offimport -countries LIST EXPORT_FILE\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var countries []string
+	for _, c := range strings.Split(*countriesFlag, ",") {
+		if tag := offdump.CountryTag(c); tag != "" {
+			countries = append(countries, tag)
+		}
+	}
+	if len(countries) == 0 || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, err := config.LoadDatabase()
+	if err != nil {
+		log.Fatalf("configuration error: %v", err)
+	}
+
+	pool, err := db.NewPool(ctx, cfg.DatabaseURL, cfg.DBSSLCACert)
+	if err != nil {
+		log.Fatalf("database connection failed: %v", err)
+	}
+	defer pool.Close()
+
+	if err := db.RunMigrations(ctx, pool); err != nil {
+		log.Fatalf("migrations failed: %v", err)
+	}
+
+	dump, err := offdump.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("open export: %v", err)
+	}
+	defer dump.Close()
+
+	// The import does not look products up.
+	productSvc := service.NewProductService(pool, nil, 0, nil)
+
+	log.Printf("importing products of %s from %s", strings.Join(countries, ", "), flag.Arg(0))
+	start := time.Now()
+	stats, err := productSvc.ImportOffline(ctx, dump, countries)
+	if err != nil {
+		log.Fatalf("import failed: %v", err)
+	}
+	log.Printf("imported %d products (%d read, %d without a valid barcode or name, %d unreadable records) in %s",
+		stats.Imported, stats.Read, stats.Skipped, stats.Bad, time.Since(start).Round(time.Second))
+}
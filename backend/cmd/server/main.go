@@ -11,6 +11,7 @@ import (
 	"foodinventory/internal/config"
 	"foodinventory/internal/db"
 	"foodinventory/internal/handler"
+	"foodinventory/internal/lookup"
 	"foodinventory/internal/service"
 )
 
@@ -40,10 +41,19 @@ func main() {
 		log.Fatalf("migrations failed: %v", err)
 	}
 
-	productSvc := service.NewProductService(pool, cfg.OFFTimeout)
-	inventorySvc := service.NewInventoryService(pool, productSvc)
+	productLookup := lookup.NewChain(cfg.LookupTimeout, cfg.LookupProviders...)
+	productSvc := service.NewProductService(pool, productLookup, cfg.NotFoundTTL, cfg.VariableMeasure)
+	inventorySvc := service.NewInventoryService(pool, productSvc, cfg.UndoWindow)
 	alertSvc := service.NewAlertService(pool)
 	settingsSvc := service.NewSettingsService(pool)
+	locationSvc := service.NewLocationService(pool)
+	historySvc := service.NewHistoryService(pool)
+	shoppingSvc := service.NewShoppingListService(pool, alertSvc, productSvc)
+	reportSvc := service.NewReportService(pool)
+	groupSvc := service.NewGroupService(pool, productSvc)
+
+	stubResolver := service.NewStubResolver(pool, productSvc, cfg.StubResolveInterval, cfg.StubResolveRate)
+	go stubResolver.Run(ctx)
 
 	mux := http.NewServeMux()
 	handler.RegisterHealth(mux, pool)
@@ -51,6 +61,11 @@ func main() {
 	handler.RegisterProduct(mux, productSvc)
 	handler.RegisterAlerts(mux, alertSvc)
 	handler.RegisterSettings(mux, settingsSvc)
+	handler.RegisterLocations(mux, locationSvc)
+	handler.RegisterHistory(mux, historySvc)
+	handler.RegisterShoppingList(mux, shoppingSvc)
+	handler.RegisterReports(mux, reportSvc)
+	handler.RegisterGroups(mux, groupSvc)
 
 	uiFS, err := fs.Sub(staticFiles, "ui")
 	if err != nil {
@@ -2,17 +2,37 @@ package config
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"foodinventory/internal/gtin"
+	"foodinventory/internal/lookup"
 )
 
 // Config holds all runtime configuration loaded from environment variables.
 type Config struct {
-	DatabaseURL   string
-	Port          string
-	DBSSLCACert   string        // PEM-encoded CA certificate; empty means use system roots
-	OFFTimeout    time.Duration // timeout for Open Food Facts HTTP requests
+	DatabaseURL string
+	Port        string
+	DBSSLCACert string        // PEM-encoded CA certificate; empty means use system roots
+	UndoWindow  time.Duration // how long after an inventory operation it can be undone
+
+	// LookupProviders resolve unknown products, asked in order; each lookup
+	// is limited to LookupTimeout. That no provider knows a product is
+	// cached for NotFoundTTL.
+	LookupProviders []lookup.ProductLookup
+	LookupTimeout   time.Duration
+	NotFoundTTL     time.Duration
+
+	// Stub products are looked up again in the background every
+	// StubResolveInterval, with at most StubResolveRate lookups per minute.
+	StubResolveInterval time.Duration
+	StubResolveRate     int
+
+	// VariableMeasure decodes in-store codes that embed a weight or price.
+	VariableMeasure []gtin.MeasureRule
 }
 
 // Load reads configuration from environment variables.
@@ -44,7 +64,93 @@ type Config struct {
 //	DB_SSL_CA_CERT        PEM-encoded CA certificate (inline)
 //	DB_SSL_CA_CERT_FILE   path to PEM CA certificate file
 //	PORT                  HTTP listen port            (default: 8080)
+//	UNDO_WINDOW_SECONDS   undo time window in seconds (default: 300)
+//	PRODUCT_LOOKUP_PROVIDERS  ordered product lookup providers
+//	                        (default: openfoodfacts), any of
+//	                        openfoodfacts | openbeautyfacts |
+//	                        openproductsfacts | http | csv
+//	PRODUCT_LOOKUP_TIMEOUT_MS  timeout per provider lookup (default: 500)
+//	PRODUCT_LOOKUP_RATE_PER_MINUTE  maximum lookups per minute with the Open
+//	                        Food Facts family of providers (default: 100)
+//	PRODUCT_LOOKUP_OFF_URL, _OBF_URL, _OPF_URL
+//	                        base URLs of Open Food Facts, Open Beauty Facts
+//	                        and Open Products Facts (default: public servers)
+//	PRODUCT_LOOKUP_ATTEMPTS  attempts per HTTP lookup, retrying network
+//	                        errors, 429 and 5xx responses (default: 3)
+//	PRODUCT_LOOKUP_BREAKER_FAILURES  consecutive failed lookups after which a
+//	                        remote provider is skipped (default: 5)
+//	PRODUCT_LOOKUP_BREAKER_COOLDOWN_SECONDS  how long a failing provider is
+//	                        skipped before it is tried again (default: 30)
+//	PRODUCT_NOT_FOUND_TTL_HOURS  how long products unknown to all providers
+//	                        are not looked up again on scan (default: 24)
+//	PRODUCT_LOOKUP_HTTP_URL  product endpoint of the http provider, with
+//	                        {ean} in place of the barcode
+//	PRODUCT_LOOKUP_HTTP_NAME_FIELD, _CATEGORY_FIELD, _IMAGE_FIELD
+//	                        JSON paths of the product fields
+//	                        (default: name, category, image_url)
+//	PRODUCT_LOOKUP_HTTP_AUTHORIZATION  Authorization header of the http provider
+//	PRODUCT_LOOKUP_CSV_FILE  path of the csv provider's file
+//	STUB_RESOLVE_INTERVAL_SECONDS  how often stubs due for another lookup
+//	                        are retried in the background (default: 60)
+//	STUB_RESOLVE_RATE_PER_MINUTE  maximum background lookups per minute
+//	                        (default: 10)
+//	VARIABLE_MEASURE_RULES  variable-measure code layouts, e.g.
+//	                        28-29:PPIIIIIWWWWW (default: none)
 func Load() (*Config, error) {
+	cfg, err := LoadDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	lookupTimeout, err := parseDurationMS("PRODUCT_LOOKUP_TIMEOUT_MS", 500)
+	if err != nil {
+		return nil, err
+	}
+
+	lookupProviders, err := loadLookupProviders()
+	if err != nil {
+		return nil, err
+	}
+
+	notFoundTTL, err := parseDurationHours("PRODUCT_NOT_FOUND_TTL_HOURS", 24)
+	if err != nil {
+		return nil, err
+	}
+
+	undoWindow, err := parseDurationSeconds("UNDO_WINDOW_SECONDS", 300)
+	if err != nil {
+		return nil, err
+	}
+
+	stubResolveInterval, err := parseDurationSeconds("STUB_RESOLVE_INTERVAL_SECONDS", 60)
+	if err != nil {
+		return nil, err
+	}
+
+	stubResolveRate, err := parsePositiveInt("STUB_RESOLVE_RATE_PER_MINUTE", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	variableMeasure, err := gtin.ParseMeasureRules(os.Getenv("VARIABLE_MEASURE_RULES"))
+	if err != nil {
+		return nil, fmt.Errorf("VARIABLE_MEASURE_RULES: %w", err)
+	}
+
+	cfg.Port = getEnv("PORT", "8080")
+	cfg.UndoWindow = undoWindow
+	cfg.LookupProviders = lookupProviders
+	cfg.LookupTimeout = lookupTimeout
+	cfg.NotFoundTTL = notFoundTTL
+	cfg.StubResolveInterval = stubResolveInterval
+	cfg.StubResolveRate = stubResolveRate
+	cfg.VariableMeasure = variableMeasure
+	return cfg, nil
+}
+
+// LoadDatabase reads only the database connection settings described at
+// Load, for tools that need nothing else; the other fields are left zero.
+func LoadDatabase() (*Config, error) {
 	dbURL := os.Getenv("DATABASE_URL")
 	if dbURL == "" {
 		dbURL = fmt.Sprintf(
@@ -62,18 +168,7 @@ func Load() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	offTimeout, err := parseDurationMS("PRODUCT_LOOKUP_TIMEOUT_MS", 500)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Config{
-		DatabaseURL: dbURL,
-		Port:        getEnv("PORT", "8080"),
-		DBSSLCACert: caCert,
-		OFFTimeout:  offTimeout,
-	}, nil
+	return &Config{DatabaseURL: dbURL, DBSSLCACert: caCert}, nil
 }
 
 // resolveSSLCACert returns the PEM-encoded CA certificate to use, or an empty
@@ -93,6 +188,85 @@ func resolveSSLCACert() (string, error) {
 	return "", nil
 }
 
+// loadLookupProviders builds the providers named in PRODUCT_LOOKUP_PROVIDERS,
+// in order, from their PRODUCT_LOOKUP_* settings. The Open Food Facts family
+// of databases share one rate limit, as they share their servers. Every
+// remote provider has its own circuit breaker.
+func loadLookupProviders() ([]lookup.ProductLookup, error) {
+	perMinute, err := parsePositiveInt("PRODUCT_LOOKUP_RATE_PER_MINUTE", 100)
+	if err != nil {
+		return nil, err
+	}
+	openFactsLimit := lookup.NewLimiter(perMinute, min(perMinute, 10))
+
+	attempts, err := parsePositiveInt("PRODUCT_LOOKUP_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+	client := lookup.NewHTTP(attempts)
+
+	failures, err := parsePositiveInt("PRODUCT_LOOKUP_BREAKER_FAILURES", 5)
+	if err != nil {
+		return nil, err
+	}
+	cooldown, err := parseDurationSeconds("PRODUCT_LOOKUP_BREAKER_COOLDOWN_SECONDS", 30)
+	if err != nil {
+		return nil, err
+	}
+	remote := func(p lookup.ProductLookup) lookup.ProductLookup {
+		return lookup.WithBreaker(p, failures, cooldown)
+	}
+	openFacts := func(p *lookup.OpenFacts) lookup.ProductLookup {
+		return remote(lookup.Limit(p, openFactsLimit))
+	}
+
+	var providers []lookup.ProductLookup
+	for _, name := range strings.Split(getEnv("PRODUCT_LOOKUP_PROVIDERS", "openfoodfacts"), ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case "openfoodfacts":
+			url := getEnv("PRODUCT_LOOKUP_OFF_URL", lookup.OpenFoodFactsURL)
+			providers = append(providers, openFacts(lookup.OpenFoodFacts(url, client)))
+		case "openbeautyfacts":
+			url := getEnv("PRODUCT_LOOKUP_OBF_URL", lookup.OpenBeautyFactsURL)
+			providers = append(providers, openFacts(lookup.OpenBeautyFacts(url, client)))
+		case "openproductsfacts":
+			url := getEnv("PRODUCT_LOOKUP_OPF_URL", lookup.OpenProductsFactsURL)
+			providers = append(providers, openFacts(lookup.OpenProductsFacts(url, client)))
+		case "http":
+			url := os.Getenv("PRODUCT_LOOKUP_HTTP_URL")
+			if !strings.Contains(url, "{ean}") {
+				return nil, fmt.Errorf("PRODUCT_LOOKUP_HTTP_URL must be set and contain {ean} for the http provider")
+			}
+			header := http.Header{}
+			if auth := os.Getenv("PRODUCT_LOOKUP_HTTP_AUTHORIZATION"); auth != "" {
+				header.Set("Authorization", auth)
+			}
+			providers = append(providers, remote(&lookup.HTTPJSON{
+				URL:           url,
+				Header:        header,
+				NameField:     getEnv("PRODUCT_LOOKUP_HTTP_NAME_FIELD", "name"),
+				CategoryField: getEnv("PRODUCT_LOOKUP_HTTP_CATEGORY_FIELD", "category"),
+				ImageField:    getEnv("PRODUCT_LOOKUP_HTTP_IMAGE_FIELD", "image_url"),
+				HTTP:          client,
+			}))
+		case "csv":
+			path := os.Getenv("PRODUCT_LOOKUP_CSV_FILE")
+			if path == "" {
+				return nil, fmt.Errorf("PRODUCT_LOOKUP_CSV_FILE must be set for the csv provider")
+			}
+			c, err := lookup.LoadCSV(path)
+			if err != nil {
+				return nil, fmt.Errorf("PRODUCT_LOOKUP_CSV_FILE: %w", err)
+			}
+			providers = append(providers, c)
+		default:
+			return nil, fmt.Errorf("PRODUCT_LOOKUP_PROVIDERS: unknown provider %q", name)
+		}
+	}
+	return providers, nil
+}
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -111,3 +285,39 @@ func parseDurationMS(key string, defaultMS int) (time.Duration, error) {
 	}
 	return time.Duration(ms) * time.Millisecond, nil
 }
+
+func parseDurationSeconds(key string, defaultS int) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return time.Duration(defaultS) * time.Second, nil
+	}
+	sec, err := strconv.Atoi(v)
+	if err != nil || sec <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer (seconds), got %q", key, v)
+	}
+	return time.Duration(sec) * time.Second, nil
+}
+
+func parseDurationHours(key string, defaultH int) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return time.Duration(defaultH) * time.Hour, nil
+	}
+	h, err := strconv.Atoi(v)
+	if err != nil || h <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer (hours), got %q", key, v)
+	}
+	return time.Duration(h) * time.Hour, nil
+}
+
+func parsePositiveInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", key, v)
+	}
+	return n, nil
+}
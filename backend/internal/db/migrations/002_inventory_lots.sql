@@ -0,0 +1,5 @@
+-- Inventory rows are stock lots: one row per EAN and expiry date, so units
+-- bought on different days keep their own expiry date. Rows for the same EAN
+-- are summed for low-stock checks and drained in expiry order on removal.
+CREATE INDEX IF NOT EXISTS inventory_ean_expiry_idx
+    ON inventory (ean, expiry_date);
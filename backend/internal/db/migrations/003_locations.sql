@@ -0,0 +1,19 @@
+-- Storage locations such as the pantry or the freezer.
+CREATE TABLE IF NOT EXISTS locations (
+    id   SERIAL PRIMARY KEY,
+    name TEXT   NOT NULL UNIQUE
+);
+
+INSERT INTO locations (name)
+VALUES ('Pantry'), ('Fridge'), ('Freezer'), ('Cellar')
+ON CONFLICT DO NOTHING;
+
+-- Lots are kept per location: the same product with the same expiry date may
+-- be stored in several places. location_id NULL means "no location assigned".
+-- A location cannot be deleted while lots reference it.
+ALTER TABLE inventory
+    ADD COLUMN IF NOT EXISTS location_id INT REFERENCES locations(id);
+
+DROP INDEX IF EXISTS inventory_ean_expiry_idx;
+CREATE INDEX IF NOT EXISTS inventory_ean_location_expiry_idx
+    ON inventory (ean, location_id, expiry_date);
@@ -0,0 +1,22 @@
+-- Append-only stock movement ledger. Every stock change appends one row per
+-- affected lot in the same transaction as the change itself; rows are never
+-- updated or deleted.
+-- quantity_after is the product's total stock (all lots) after the change.
+-- ean, inventory_id and location_id carry no foreign keys so the history
+-- survives deleted lots and locations.
+CREATE TABLE IF NOT EXISTS inventory_events (
+    id             BIGSERIAL   PRIMARY KEY,
+    occurred_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ean            VARCHAR(13) NOT NULL,
+    inventory_id   INT,
+    location_id    INT,
+    delta          INT         NOT NULL,
+    quantity_after INT         NOT NULL,
+    source         TEXT        NOT NULL,
+    reason         TEXT
+);
+
+CREATE INDEX IF NOT EXISTS inventory_events_occurred_at_idx
+    ON inventory_events (occurred_at);
+CREATE INDEX IF NOT EXISTS inventory_events_ean_occurred_at_idx
+    ON inventory_events (ean, occurred_at);
@@ -0,0 +1,27 @@
+-- Undo support for the stock movement ledger.
+--
+-- operation_id groups the events written by one API call (a move touches
+-- several lots) so the operation can be reverted as a whole.
+-- expiry_date and low_stock_threshold complete the snapshot of the affected
+-- lot, so a lot deleted at quantity 0 can be recreated exactly.
+-- An undo appends its own events with reverts_operation_id pointing at the
+-- reverted operation; the ledger stays append-only.
+CREATE SEQUENCE IF NOT EXISTS inventory_operation_seq;
+
+ALTER TABLE inventory_events
+    ADD COLUMN IF NOT EXISTS operation_id         BIGINT,
+    ADD COLUMN IF NOT EXISTS expiry_date          DATE,
+    ADD COLUMN IF NOT EXISTS low_stock_threshold  INT,
+    ADD COLUMN IF NOT EXISTS reverts_operation_id BIGINT;
+
+UPDATE inventory_events
+SET operation_id = nextval('inventory_operation_seq')
+WHERE operation_id IS NULL;
+
+ALTER TABLE inventory_events
+    ALTER COLUMN operation_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS inventory_events_operation_id_idx
+    ON inventory_events (operation_id);
+CREATE INDEX IF NOT EXISTS inventory_events_reverts_operation_id_idx
+    ON inventory_events (reverts_operation_id);
@@ -0,0 +1,7 @@
+-- Watch list: products marked "always keep in stock" carry a product-level
+-- minimum quantity. Unlike inventory.low_stock_threshold it survives the
+-- deletion of the last lot, so watched products raise an out_of_stock alert
+-- when no stock is left. NULL means the product is not watched.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS min_quantity INT
+        CHECK (min_quantity > 0);
@@ -0,0 +1,24 @@
+-- Quantity the shopping list tops a product up to when it runs low.
+-- NULL means one more than the low-stock threshold.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS target_quantity INT
+        CHECK (target_quantity > 0);
+
+-- Shopping list. Items either reference a product (ean) or are free text
+-- (name). automatic = TRUE marks items maintained from low-stock and
+-- out-of-stock conditions; they are updated or dropped as stock changes
+-- until they are checked off.
+CREATE TABLE IF NOT EXISTS shopping_list_items (
+    id         SERIAL      PRIMARY KEY,
+    ean        VARCHAR(13) REFERENCES products(ean),
+    name       TEXT,
+    quantity   INT         NOT NULL DEFAULT 1
+                   CHECK (quantity > 0),
+    checked    BOOLEAN     NOT NULL DEFAULT FALSE,
+    automatic  BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (ean IS NOT NULL OR name IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS shopping_list_items_ean_idx
+    ON shopping_list_items (ean);
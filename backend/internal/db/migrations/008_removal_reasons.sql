@@ -0,0 +1,21 @@
+-- Removal reasons for food-waste reporting.
+--
+-- inventory_events.reason becomes one of a fixed set of reasons. Free-text
+-- reasons recorded before are kept in the new note column and classified as
+-- 'other'.
+ALTER TABLE inventory_events
+    ADD COLUMN IF NOT EXISTS note TEXT;
+
+UPDATE inventory_events
+SET note = reason, reason = 'other'
+WHERE reason IS NOT NULL
+  AND reason NOT IN ('consumed', 'expired', 'spoiled', 'donated', 'other');
+
+ALTER TABLE inventory_events
+    ADD CONSTRAINT inventory_events_reason_check
+        CHECK (reason IN ('consumed', 'expired', 'spoiled', 'donated', 'other'));
+
+-- Waste reports only read discarded units.
+CREATE INDEX IF NOT EXISTS inventory_events_waste_idx
+    ON inventory_events (occurred_at)
+    WHERE reason IN ('expired', 'spoiled');
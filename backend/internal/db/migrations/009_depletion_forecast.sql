@@ -0,0 +1,5 @@
+-- Consumption forecasting: a depletion_soon alert fires when a product is
+-- projected to reach its low-stock threshold within this many days.
+ALTER TABLE settings
+    ADD COLUMN IF NOT EXISTS depletion_horizon_days INT NOT NULL DEFAULT 7
+        CHECK (depletion_horizon_days > 0);
@@ -0,0 +1,40 @@
+-- Measured quantities.
+--
+-- Every product has a unit its amounts are stored in: pieces, grams or
+-- millilitres (kg and l are converted on input). package_size is the amount
+-- in one package; NULL means one unit per package and is only allowed for
+-- pieces. low_stock_amount is an optional product-level low-stock threshold
+-- in the product's unit that replaces the per-lot package threshold.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS unit TEXT NOT NULL DEFAULT 'pcs'
+        CHECK (unit IN ('pcs', 'g', 'ml')),
+    ADD COLUMN IF NOT EXISTS package_size NUMERIC(12, 3)
+        CHECK (package_size > 0),
+    ADD COLUMN IF NOT EXISTS low_stock_amount NUMERIC(12, 3)
+        CHECK (low_stock_amount > 0);
+
+ALTER TABLE products
+    ADD CONSTRAINT products_package_size_check
+        CHECK (unit = 'pcs' OR package_size IS NOT NULL);
+
+-- amount is what is left of a lot in the product's unit. quantity stays the
+-- number of packages, including a partly used one:
+-- quantity = CEIL(amount / package_size).
+ALTER TABLE inventory
+    ADD COLUMN IF NOT EXISTS amount NUMERIC(12, 3);
+
+UPDATE inventory SET amount = quantity WHERE amount IS NULL;
+
+ALTER TABLE inventory
+    ALTER COLUMN amount SET NOT NULL,
+    ADD CONSTRAINT inventory_amount_check CHECK (amount > 0);
+
+-- amount_delta is the change of the lot's amount; delta stays the change in
+-- packages and is 0 when only part of a package was taken.
+ALTER TABLE inventory_events
+    ADD COLUMN IF NOT EXISTS amount_delta NUMERIC(12, 3);
+
+UPDATE inventory_events SET amount_delta = delta WHERE amount_delta IS NULL;
+
+ALTER TABLE inventory_events
+    ALTER COLUMN amount_delta SET NOT NULL;
@@ -0,0 +1,14 @@
+-- Opened packages.
+--
+-- opened_at marks lots of opened units; they are split off the unopened lot
+-- and used first. days_after_opening is how long a product stays good once
+-- opened; opened lots raise an opened_expiring alert based on it.
+ALTER TABLE inventory
+    ADD COLUMN IF NOT EXISTS opened_at DATE;
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS days_after_opening INT
+        CHECK (days_after_opening > 0);
+
+ALTER TABLE inventory_events
+    ADD COLUMN IF NOT EXISTS opened_at DATE;
@@ -0,0 +1,6 @@
+-- Internal product codes.
+--
+-- Products without a barcode (produce, bulk and homemade food) are assigned
+-- EAN-13 codes from the GS1 restricted-circulation range. The sequence numbers
+-- them; the code is built from it in the service layer.
+CREATE SEQUENCE IF NOT EXISTS internal_product_code_seq;
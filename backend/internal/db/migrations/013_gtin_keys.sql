@@ -0,0 +1,91 @@
+-- Canonical GTIN keys.
+--
+-- Barcodes are stored as canonical keys: the GTIN-13 for EAN-8, UPC-E, UPC-A
+-- and EAN-13 codes, or all 14 digits for GTIN-14 codes of trade units. The
+-- columns are widened for the latter, and existing 8-digit keys are rewritten:
+-- valid EAN-8 codes are padded with five leading zeros, and other codes that
+-- are valid UPC-E codes are expanded to their UPC-A. Codes that are neither
+-- keep their key and are reported with a warning in the database log, as they
+-- can no longer be scanned; merge them into the correct product. Keys of
+-- other lengths are not rewritten; those that are not valid GTIN-13 or
+-- GTIN-14 keys, e.g. with a wrong check digit, are reported with a warning
+-- too. The ledger is rewritten as well, so a product's history keeps
+-- following it. The foreign keys are dropped while the keys are rewritten.
+ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_ean_fkey;
+ALTER TABLE shopping_list_items DROP CONSTRAINT IF EXISTS shopping_list_items_ean_fkey;
+
+ALTER TABLE products ALTER COLUMN ean TYPE VARCHAR(14);
+ALTER TABLE inventory ALTER COLUMN ean TYPE VARCHAR(14);
+ALTER TABLE inventory_events ALTER COLUMN ean TYPE VARCHAR(14);
+ALTER TABLE shopping_list_items ALTER COLUMN ean TYPE VARCHAR(14);
+
+-- GS1 mod-10 check: weights 3 and 1 alternate from the right, starting left
+-- of the check digit.
+CREATE FUNCTION pg_temp.gtin_valid(code TEXT) RETURNS BOOLEAN
+LANGUAGE SQL IMMUTABLE AS $$
+    SELECT CASE WHEN code ~ '^[0-9]{8,14}$' THEN (
+        SELECT (10 - sum(substr(code, length(code) - i, 1)::int
+                         * CASE WHEN i % 2 = 1 THEN 3 ELSE 1 END) % 10) % 10
+        FROM generate_series(1, length(code) - 1) AS i
+    ) = right(code, 1)::int ELSE FALSE END
+$$;
+
+-- The UPC-A abbreviated by a UPC-E code of number system 0 or 1, else NULL.
+CREATE FUNCTION pg_temp.upce_to_upca(code TEXT) RETURNS TEXT
+LANGUAGE SQL IMMUTABLE AS $$
+    SELECT CASE WHEN code ~ '^[01][0-9]{7}$' THEN
+        left(code, 1) || CASE
+            WHEN substr(code, 7, 1) IN ('0', '1', '2')
+                THEN substr(code, 2, 2) || substr(code, 7, 1) || '0000' || substr(code, 4, 3)
+            WHEN substr(code, 7, 1) = '3'
+                THEN substr(code, 2, 3) || '00000' || substr(code, 5, 2)
+            WHEN substr(code, 7, 1) = '4'
+                THEN substr(code, 2, 4) || '00000' || substr(code, 6, 1)
+            ELSE substr(code, 2, 5) || '0000' || substr(code, 7, 1)
+        END || right(code, 1)
+    END
+$$;
+
+CREATE TEMP TABLE gtin_rekey ON COMMIT DROP AS
+SELECT old, CASE
+    WHEN pg_temp.gtin_valid(old) THEN '00000' || old
+    WHEN pg_temp.gtin_valid(pg_temp.upce_to_upca(old)) THEN '0' || pg_temp.upce_to_upca(old)
+END AS new
+FROM (
+    SELECT ean AS old FROM products
+    UNION SELECT ean FROM inventory_events
+) codes
+WHERE length(old) = 8;
+
+-- A code whose canonical key is already taken by another product stays
+-- unchanged too.
+UPDATE gtin_rekey SET new = NULL WHERE new IN (SELECT ean FROM products);
+
+DO $$
+DECLARE
+    invalid TEXT;
+BEGIN
+    SELECT string_agg(old, ', ' ORDER BY old) INTO invalid
+    FROM gtin_rekey WHERE new IS NULL;
+    IF invalid IS NOT NULL THEN
+        RAISE WARNING 'barcodes left unchanged, invalid or their key is taken: %', invalid;
+    END IF;
+
+    SELECT string_agg(ean, ', ' ORDER BY ean) INTO invalid
+    FROM products
+    WHERE length(ean) <> 8
+      AND NOT (length(ean) IN (13, 14) AND pg_temp.gtin_valid(ean));
+    IF invalid IS NOT NULL THEN
+        RAISE WARNING 'barcodes that are not valid GTIN-13 or GTIN-14 keys and will not match a scan: %', invalid;
+    END IF;
+END $$;
+
+UPDATE products t SET ean = k.new FROM gtin_rekey k WHERE t.ean = k.old AND k.new IS NOT NULL;
+UPDATE inventory t SET ean = k.new FROM gtin_rekey k WHERE t.ean = k.old AND k.new IS NOT NULL;
+UPDATE inventory_events t SET ean = k.new FROM gtin_rekey k WHERE t.ean = k.old AND k.new IS NOT NULL;
+UPDATE shopping_list_items t SET ean = k.new FROM gtin_rekey k WHERE t.ean = k.old AND k.new IS NOT NULL;
+
+ALTER TABLE inventory
+    ADD CONSTRAINT inventory_ean_fkey FOREIGN KEY (ean) REFERENCES products(ean);
+ALTER TABLE shopping_list_items
+    ADD CONSTRAINT shopping_list_items_ean_fkey FOREIGN KEY (ean) REFERENCES products(ean);
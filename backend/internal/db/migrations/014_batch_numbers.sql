@@ -0,0 +1,10 @@
+-- Batch numbers.
+--
+-- batch is the batch/lot number printed on the package, e.g. read from GS1
+-- AI (10). Units with different batch numbers are kept in separate lots, so a
+-- recalled batch can be found; the ledger records it alongside the lot.
+ALTER TABLE inventory
+    ADD COLUMN IF NOT EXISTS batch TEXT;
+
+ALTER TABLE inventory_events
+    ADD COLUMN IF NOT EXISTS batch TEXT;
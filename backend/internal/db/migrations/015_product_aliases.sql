@@ -0,0 +1,15 @@
+-- Product aliases.
+--
+-- An alias is another barcode of a product: a regional variant, multipack or
+-- redesigned package. Stock changes made through an alias are booked on the
+-- product it points to, and ledger events recorded under an alias (such as
+-- those of a product that was merged into another one) are attributed to that
+-- product.
+CREATE TABLE IF NOT EXISTS product_aliases (
+    alias      VARCHAR(14) PRIMARY KEY,
+    ean        VARCHAR(14) NOT NULL REFERENCES products(ean),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS product_aliases_ean_idx
+    ON product_aliases (ean);
@@ -0,0 +1,18 @@
+-- Product groups.
+--
+-- A group collects interchangeable products, such as several brands of milk,
+-- under a group-level minimum quantity. Stock alerts of grouped products are
+-- raised for the group on the summed quantity of its members instead of per
+-- product. A product belongs to at most one group.
+CREATE TABLE IF NOT EXISTS product_groups (
+    id           SERIAL PRIMARY KEY,
+    name         TEXT NOT NULL UNIQUE,
+    min_quantity INT  NOT NULL CHECK (min_quantity > 0)
+);
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS group_id INT
+        REFERENCES product_groups(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS products_group_id_idx
+    ON products (group_id);
@@ -0,0 +1,15 @@
+-- Package contents.
+--
+-- The barcode of a case or multipack (a tray of six bottles of water) can
+-- declare that it contains contains_quantity units of the product
+-- contains_ean. Scanning the outer code in or out of stock then books the
+-- contained units instead of one package. Both columns are set together.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS contains_ean VARCHAR(14) REFERENCES products(ean),
+    ADD COLUMN IF NOT EXISTS contains_quantity INT
+        CHECK (contains_quantity > 0);
+
+ALTER TABLE products
+    ADD CONSTRAINT products_contents_check
+        CHECK ((contains_ean IS NULL) = (contains_quantity IS NULL)
+               AND contains_ean <> ean);
@@ -0,0 +1,12 @@
+-- Background lookups of stub products.
+--
+-- Stubs (resolved = FALSE) are looked up again in the background until a
+-- provider knows them or they are named manually. Failed attempts back off
+-- exponentially per product: lookup_attempts counts them and next_lookup_at
+-- is when the next one is due (NULL means right away).
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS lookup_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS next_lookup_at  TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS products_stub_lookup_idx
+    ON products (next_lookup_at) WHERE NOT resolved;
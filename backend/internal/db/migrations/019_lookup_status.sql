@@ -0,0 +1,12 @@
+-- Outcome of the last product lookup.
+--
+-- lookup_status records whether the last lookup found the product, found
+-- that no provider knows it (not_found) or failed, e.g. timed out (failed);
+-- looked_up_at is when it ran. Fresh not_found results are served from the
+-- cache, so repeated scans of products that no provider knows (house brands)
+-- do not wait for the providers again. NULL means the product was never
+-- looked up, e.g. because it was created manually.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS lookup_status TEXT
+        CHECK (lookup_status IN ('found', 'not_found', 'failed')),
+    ADD COLUMN IF NOT EXISTS looked_up_at TIMESTAMPTZ;
@@ -0,0 +1,12 @@
+-- Offline product database.
+--
+-- Products imported from an Open Food Facts data export by the offimport
+-- command, keyed by canonical barcode. Unknown products are looked up here
+-- before any network request, so scans resolve without a working internet
+-- connection. Every import replaces the whole table.
+CREATE TABLE IF NOT EXISTS offline_products (
+    ean       VARCHAR(14) PRIMARY KEY,
+    name      TEXT NOT NULL,
+    category  TEXT,
+    image_url TEXT
+);
@@ -0,0 +1,11 @@
+-- Rate-limited lookups.
+--
+-- lookup_status may also be rate_limited: the last lookup was not sent
+-- because the provider rate limit was used up. The constraint is the one
+-- added with the column by 019_lookup_status.sql.
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_lookup_status_check;
+
+ALTER TABLE products
+    ADD CONSTRAINT products_lookup_status_check
+        CHECK (lookup_status IN ('found', 'not_found', 'failed', 'rate_limited'));
@@ -0,0 +1,302 @@
+// Package gs1 parses GS1 element strings as encoded in GS1 DataMatrix,
+// GS1-128 and GS1 QR codes: a sequence of application identifiers (AIs),
+// each followed by its data field.
+package gs1
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"foodinventory/internal/gtin"
+)
+
+// GroupSeparator (ASCII 29) is how scanners transmit FNC1. It terminates
+// variable-length fields that are followed by another element.
+const GroupSeparator = '\x1d'
+
+// Application identifiers read by the inventory.
+const (
+	AIGTIN       = "01"
+	AIBatch      = "10"
+	AIBestBefore = "15"
+	AIExpiry     = "17"
+)
+
+// aiSpec describes the AIs starting with a given two-digit prefix.
+type aiSpec struct {
+	aiLen   int  // digits of the AI itself
+	length  int  // fixed data length; 0 for variable-length fields
+	max     int  // maximum length of variable-length fields
+	numeric bool // data consists of digits only
+}
+
+// specs maps the first two digits of an AI to its spec. Fixed lengths are
+// only given for the GS1 predefined-length prefixes, which are the only ones
+// that may be followed by another element without a separator.
+var specs = map[string]aiSpec{
+	"00": {2, 18, 0, true},
+	"01": {2, 14, 0, true},
+	"02": {2, 14, 0, true},
+	"10": {2, 0, 20, false},
+	"11": {2, 6, 0, true},
+	"12": {2, 6, 0, true},
+	"13": {2, 6, 0, true},
+	"15": {2, 6, 0, true},
+	"16": {2, 6, 0, true},
+	"17": {2, 6, 0, true},
+	"20": {2, 2, 0, true},
+	"21": {2, 0, 20, false},
+	"22": {2, 0, 20, false},
+	"24": {3, 0, 30, false},
+	"25": {3, 0, 30, false},
+	"30": {2, 0, 8, true},
+	"31": {4, 6, 0, true},
+	"32": {4, 6, 0, true},
+	"33": {4, 6, 0, true},
+	"34": {4, 6, 0, true},
+	"35": {4, 6, 0, true},
+	"36": {4, 6, 0, true},
+	"37": {2, 0, 8, true},
+	"39": {4, 0, 18, true},
+	"40": {3, 0, 30, false},
+	"41": {3, 13, 0, true},
+	"42": {3, 0, 20, false},
+	"70": {4, 0, 30, false},
+	"71": {3, 0, 20, false},
+	"80": {4, 0, 30, false},
+	"81": {4, 0, 70, false},
+	"82": {4, 0, 70, false},
+	"90": {2, 0, 30, false},
+	"91": {2, 0, 90, false},
+	"92": {2, 0, 90, false},
+	"93": {2, 0, 90, false},
+	"94": {2, 0, 90, false},
+	"95": {2, 0, 90, false},
+	"96": {2, 0, 90, false},
+	"97": {2, 0, 90, false},
+	"98": {2, 0, 90, false},
+	"99": {2, 0, 90, false},
+}
+
+// Element is one application identifier with its data field.
+type Element struct {
+	AI   string
+	Data string
+}
+
+// ElementString is a parsed element string.
+type ElementString []Element
+
+// Get returns the data of the first element with the given AI.
+func (es ElementString) Get(ai string) (string, bool) {
+	for _, e := range es {
+		if e.AI == ai {
+			return e.Data, true
+		}
+	}
+	return "", false
+}
+
+// Date returns the date held by a date AI (11 to 17) as YYYY-MM-DD. A day of
+// 00 stands for the last day of the month.
+func (es ElementString) Date(ai string) (string, bool) {
+	data, ok := es.Get(ai)
+	if !ok {
+		return "", false
+	}
+	d, ok := parseDate(data, time.Now())
+	if !ok {
+		return "", false
+	}
+	return d.Format("2006-01-02"), true
+}
+
+// ParseError describes why an element string was rejected. Offset is the
+// byte offset in the input at which the offending element starts.
+type ParseError struct {
+	Offset int    `json:"offset"`
+	AI     string `json:"ai,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (e *ParseError) Error() string {
+	if e.AI != "" {
+		return fmt.Sprintf("gs1: AI (%s) at offset %d: %s", e.AI, e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("gs1: offset %d: %s", e.Offset, e.Reason)
+}
+
+// Parse parses a scanned element string. It accepts the raw form with an
+// optional symbology identifier (such as ]d2 or ]C1) and group separators,
+// as well as the human-readable form with bracketed AIs, e.g.
+// (01)04012345678901(17)261231(10)AB12. Errors are of type *ParseError.
+func Parse(s string) (ElementString, error) {
+	var (
+		es  ElementString
+		err error
+	)
+	if strings.HasPrefix(s, "(") {
+		es, err = parseBracketed(s)
+	} else {
+		es, err = parseRaw(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(es) == 0 {
+		return nil, &ParseError{Reason: "element string is empty"}
+	}
+	return es, nil
+}
+
+func parseRaw(s string) (ElementString, error) {
+	offset := 0
+	if strings.HasPrefix(s, "]") && len(s) >= 3 {
+		offset = 3
+	}
+
+	var es ElementString
+	for offset < len(s) {
+		if s[offset] == GroupSeparator {
+			offset++
+			continue
+		}
+		start := offset
+		ai, spec, ok := lookup(s[offset:])
+		if !ok {
+			return nil, &ParseError{Offset: start, Reason: "unknown application identifier"}
+		}
+		offset += len(ai)
+
+		end := len(s)
+		if spec.length > 0 {
+			end = min(offset+spec.length, len(s))
+		} else if i := strings.IndexByte(s[offset:], GroupSeparator); i >= 0 {
+			end = offset + i
+		}
+		data := s[offset:end]
+		offset = end
+
+		if reason := check(ai, data, spec); reason != "" {
+			return nil, &ParseError{Offset: start, AI: ai, Reason: reason}
+		}
+		es = append(es, Element{AI: ai, Data: data})
+	}
+	return es, nil
+}
+
+func parseBracketed(s string) (ElementString, error) {
+	var es ElementString
+	offset := 0
+	for offset < len(s) {
+		start := offset
+		if s[offset] != '(' {
+			return nil, &ParseError{Offset: start, Reason: "expected ( before application identifier"}
+		}
+		closing := strings.IndexByte(s[offset:], ')')
+		if closing < 0 {
+			return nil, &ParseError{Offset: start, Reason: "missing ) after application identifier"}
+		}
+		ai := s[offset+1 : offset+closing]
+		known, spec, ok := lookup(ai)
+		if !ok || known != ai {
+			return nil, &ParseError{Offset: start, AI: ai, Reason: "unknown application identifier"}
+		}
+		offset += closing + 1
+
+		end := len(s)
+		if i := strings.IndexByte(s[offset:], '('); i >= 0 {
+			end = offset + i
+		}
+		data := s[offset:end]
+		offset = end
+
+		if reason := check(ai, data, spec); reason != "" {
+			return nil, &ParseError{Offset: start, AI: ai, Reason: reason}
+		}
+		es = append(es, Element{AI: ai, Data: data})
+	}
+	return es, nil
+}
+
+// lookup returns the AI at the start of s and its spec.
+func lookup(s string) (string, aiSpec, bool) {
+	if len(s) < 2 {
+		return "", aiSpec{}, false
+	}
+	spec, ok := specs[s[:2]]
+	if !ok || len(s) < spec.aiLen || !isDigits(s[:spec.aiLen]) {
+		return "", aiSpec{}, false
+	}
+	return s[:spec.aiLen], spec, true
+}
+
+// check validates the data field of an element and returns the reason it is
+// invalid, or "" when it is valid.
+func check(ai, data string, spec aiSpec) string {
+	switch {
+	case data == "":
+		return "data is missing"
+	case spec.length > 0 && len(data) != spec.length:
+		return fmt.Sprintf("data must be %d characters", spec.length)
+	case spec.length == 0 && len(data) > spec.max:
+		return fmt.Sprintf("data must be at most %d characters", spec.max)
+	case spec.numeric && !isDigits(data):
+		return "data must be numeric"
+	}
+	switch ai[:2] {
+	case "01", "02":
+		if !gtin.Valid(data) {
+			return "GTIN check digit does not match"
+		}
+	case "11", "12", "13", "15", "16", "17":
+		if _, ok := parseDate(data, time.Now()); !ok {
+			return "data is not a valid YYMMDD date"
+		}
+	}
+	return ""
+}
+
+// parseDate parses a YYMMDD date. The century is chosen as specified by GS1:
+// the year lies within 49 years before and 50 years after now. A day of 00
+// stands for the last day of the month.
+func parseDate(data string, now time.Time) (time.Time, bool) {
+	if len(data) != 6 || !isDigits(data) {
+		return time.Time{}, false
+	}
+	yy := int(data[0]-'0')*10 + int(data[1]-'0')
+	month := int(data[2]-'0')*10 + int(data[3]-'0')
+	day := int(data[4]-'0')*10 + int(data[5]-'0')
+	if month < 1 || month > 12 {
+		return time.Time{}, false
+	}
+
+	century := now.Year() / 100 * 100
+	switch diff := yy - now.Year()%100; {
+	case diff >= 51:
+		century -= 100
+	case diff <= -50:
+		century += 100
+	}
+	year := century + yy
+
+	if day == 0 {
+		// Day 0 of the next month is the last day of this one.
+		return time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC), true
+	}
+	d := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if d.Day() != day {
+		return time.Time{}, false
+	}
+	return d, true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package gs1
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ElementString
+	}{
+		{
+			"]d201040063813339311726063010L2306A",
+			ElementString{{AIGTIN, "04006381333931"}, {AIExpiry, "260630"}, {AIBatch, "L2306A"}},
+		},
+		{
+			// A variable-length field terminated by a group separator.
+			"0104006381333931" + "10L2306A\x1d" + "15260600",
+			ElementString{{AIGTIN, "04006381333931"}, {AIBatch, "L2306A"}, {AIBestBefore, "260600"}},
+		},
+		{
+			"]C1010400638133393117261231",
+			ElementString{{AIGTIN, "04006381333931"}, {AIExpiry, "261231"}},
+		},
+		{
+			"(01)04006381333931(17)261231(10)AB12",
+			ElementString{{AIGTIN, "04006381333931"}, {AIExpiry, "261231"}, {AIBatch, "AB12"}},
+		},
+		{
+			// Four-digit AI (net weight in kg, 3 decimals).
+			"01040063813339313103001250",
+			ElementString{{AIGTIN, "04006381333931"}, {"3103", "001250"}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ParseError
+	}{
+		{"", ParseError{Reason: "element string is empty"}},
+		{"]d2", ParseError{Reason: "element string is empty"}},
+		{"0104006381333932", ParseError{AI: "01", Reason: "GTIN check digit does not match"}},
+		{"01040063813339", ParseError{AI: "01", Reason: "data must be 14 characters"}},
+		{"010400638133393117261331", ParseError{Offset: 16, AI: "17", Reason: "data is not a valid YYMMDD date"}},
+		{"010400638133393117260231", ParseError{Offset: 16, AI: "17", Reason: "data is not a valid YYMMDD date"}},
+		{"0104006381333931" + "04123", ParseError{Offset: 16, Reason: "unknown application identifier"}},
+		{"(01)04006381333931(17)", ParseError{Offset: 18, AI: "17", Reason: "data is missing"}},
+		{"(01)04006381333931(1", ParseError{Offset: 18, Reason: "missing ) after application identifier"}},
+		{"(011)04006381333931", ParseError{AI: "011", Reason: "unknown application identifier"}},
+		{"10" + "ABCDEFGHIJKLMNOPQRSTU", ParseError{AI: "10", Reason: "data must be at most 20 characters"}},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.in)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q) error = %v, want *ParseError", tt.in, err)
+			continue
+		}
+		if *perr != tt.want {
+			t.Errorf("Parse(%q) error = %+v, want %+v", tt.in, *perr, tt.want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		data string
+		want string // "" when invalid
+	}{
+		{"261231", "2026-12-31"},
+		{"260600", "2026-06-30"}, // day 00: last day of the month
+		{"240200", "2024-02-29"}, // leap year
+		{"250200", "2025-02-28"},
+		{"260229", ""},
+		{"261301", ""},
+		{"260001", ""},
+		{"26063", ""},
+		{"2606ab", ""},
+		// Century: within 49 years before and 50 years after now.
+		{"760101", "2076-01-01"}, // 50 years ahead
+		{"770101", "1977-01-01"}, // 51 years ahead is in the past
+		{"770100", "1977-01-31"},
+		{"771231", "1977-12-31"},
+		{"000101", "2000-01-01"},
+	}
+	for _, tt := range tests {
+		d, ok := parseDate(tt.data, now)
+		got := ""
+		if ok {
+			got = d.Format("2006-01-02")
+		}
+		if got != tt.want {
+			t.Errorf("parseDate(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+
+	// Near the end of a century, early two-digit years are in the next one.
+	now = time.Date(2090, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for data, want := range map[string]string{
+		"400101": "2140-01-01", // 50 years ahead
+		"410101": "2041-01-01", // 49 years back
+		"950101": "2095-01-01",
+	} {
+		d, ok := parseDate(data, now)
+		if !ok || d.Format("2006-01-02") != want {
+			t.Errorf("parseDate(%q) in 2090 = %v, %v; want %s", data, d, ok, want)
+		}
+	}
+}
+
+func TestElementStringDate(t *testing.T) {
+	es := ElementString{{AIGTIN, "04006381333931"}, {AIExpiry, "260600"}}
+	if got, ok := es.Date(AIExpiry); !ok || got != "2026-06-30" {
+		t.Errorf("Date(17) = %q, %v; want 2026-06-30", got, ok)
+	}
+	if _, ok := es.Date(AIBestBefore); ok {
+		t.Error("Date(15) found a date that is not there")
+	}
+}
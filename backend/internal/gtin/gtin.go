@@ -0,0 +1,214 @@
+// Package gtin checks and normalizes GS1 barcode numbers (EAN-8, UPC-E,
+// UPC-A, EAN-13 and GTIN-14).
+package gtin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// internalPrefix starts the codes assigned by Internal. GS1 reserves EAN-13
+// codes beginning with 2 for restricted circulation within a company, so they
+// never collide with codes printed by manufacturers.
+const internalPrefix = "20"
+
+// ean8Prefix pads an EAN-8 to its GTIN-13 form.
+const ean8Prefix = "00000"
+
+var (
+	// ErrFormat is returned by Normalize for codes that are not 8, 12, 13 or
+	// 14 digits.
+	ErrFormat = errors.New("gtin: must be 8, 12, 13 or 14 digits")
+	// ErrCheckDigit is returned by Normalize when the last digit does not
+	// match the GS1 check digit, which usually means a mis-read barcode.
+	ErrCheckDigit = errors.New("gtin: check digit mismatch")
+	// ErrSymbology is returned by NormalizeAs for codes whose length does
+	// not fit the given symbology.
+	ErrSymbology = errors.New("gtin: code does not fit the symbology")
+)
+
+// Symbology is the barcode symbology a code was scanned from, named as by
+// the browser Barcode Detection API.
+type Symbology string
+
+// Symbologies of GS1 product barcodes.
+const (
+	EAN8  Symbology = "ean_8"
+	UPCE  Symbology = "upc_e"
+	UPCA  Symbology = "upc_a"
+	EAN13 Symbology = "ean_13"
+)
+
+// Valid reports whether s is one of the known symbologies.
+func (s Symbology) Valid() bool {
+	switch s {
+	case EAN8, UPCE, UPCA, EAN13:
+		return true
+	}
+	return false
+}
+
+// CheckDigit returns the GS1 mod-10 check digit for digits, the code without
+// its final check digit. Weights 3 and 1 alternate from the right.
+func CheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i -= 2 {
+		sum += 3 * int(digits[i]-'0')
+		if i > 0 {
+			sum += int(digits[i-1] - '0')
+		}
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// Valid reports whether code consists of digits and ends with the correct
+// check digit.
+func Valid(code string) bool {
+	return len(code) >= 2 && isDigits(code) &&
+		code[len(code)-1] == CheckDigit(code[:len(code)-1])
+}
+
+// Normalize verifies the check digit of a scanned code and returns its
+// canonical key: the GTIN-13 for EAN-8, UPC-E, UPC-A and EAN-13 codes, and for
+// GTIN-14 codes with indicator digit 0. Other GTIN-14 codes identify trade
+// units such as cases and keep all 14 digits.
+//
+// An 8-digit code is read as EAN-8; when its check digit does not fit, it is
+// tried as UPC-E (number system 0 or 1) before ErrCheckDigit is returned.
+// Some codes are valid both ways and denote different products; use
+// NormalizeAs when the symbology is known.
+func Normalize(code string) (string, error) {
+	if !isDigits(code) {
+		return "", ErrFormat
+	}
+	switch len(code) {
+	case 8:
+		if Valid(code) {
+			return ean8Prefix + code, nil
+		}
+		if upcA, ok := expandUPCE(code); ok && Valid(upcA) {
+			return "0" + upcA, nil
+		}
+		return "", ErrCheckDigit
+	case 12, 13, 14:
+		if !Valid(code) {
+			return "", ErrCheckDigit
+		}
+		switch {
+		case len(code) == 12:
+			return "0" + code, nil
+		case len(code) == 14 && code[0] == '0':
+			return code[1:], nil
+		}
+		return code, nil
+	}
+	return "", ErrFormat
+}
+
+// NormalizeAs is like Normalize for a code scanned from the given symbology:
+// 8-digit codes are read only as EAN-8 or only as UPC-E, as the symbology
+// says. An empty symbology means unknown.
+func NormalizeAs(code string, sym Symbology) (string, error) {
+	if !isDigits(code) {
+		return "", ErrFormat
+	}
+	switch sym {
+	case "":
+		return Normalize(code)
+	case EAN8:
+		if len(code) != 8 {
+			return "", ErrSymbology
+		}
+		if !Valid(code) {
+			return "", ErrCheckDigit
+		}
+		return ean8Prefix + code, nil
+	case UPCE:
+		if len(code) != 8 {
+			return "", ErrSymbology
+		}
+		upcA, ok := expandUPCE(code)
+		if !ok {
+			return "", ErrSymbology
+		}
+		if !Valid(upcA) {
+			return "", ErrCheckDigit
+		}
+		return "0" + upcA, nil
+	case UPCA:
+		// Some scanners report UPC-A codes as EAN-13 with a leading zero.
+		if len(code) != 12 && (len(code) != 13 || code[0] != '0') {
+			return "", ErrSymbology
+		}
+	case EAN13:
+		if len(code) != 13 {
+			return "", ErrSymbology
+		}
+	}
+	return Normalize(code)
+}
+
+// Compact returns the shortest standard form of a canonical key: the EAN-8
+// for padded EAN-8 codes, and key itself otherwise. Product databases such as
+// Open Food Facts list EAN-8 products under their eight digits.
+func Compact(key string) string {
+	if len(key) == 13 && strings.HasPrefix(key, ean8Prefix) {
+		return key[len(ean8Prefix):]
+	}
+	return key
+}
+
+// Restricted reports whether the canonical key is from one of the GS1
+// restricted-circulation ranges: in-store labels, variable-measure items and
+// internal codes that are unknown to public product databases.
+func Restricted(key string) bool {
+	if len(key) != 13 {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(key, ean8Prefix):
+		return key[5] == '2'
+	case key[0] == '0':
+		// Prefixes 02 and 04, which include UPC number systems 2 and 4.
+		return key[1] == '2' || key[1] == '4'
+	}
+	return key[0] == '2'
+}
+
+// Internal returns the n-th internal EAN-13: the prefix 20, n padded to ten
+// digits and the check digit.
+func Internal(n int64) string {
+	digits := fmt.Sprintf("%s%010d", internalPrefix, n)
+	return digits + string(CheckDigit(digits))
+}
+
+// expandUPCE expands an 8-digit UPC-E code (number system, six digits, check
+// digit) to the 12-digit UPC-A it abbreviates.
+func expandUPCE(code string) (string, bool) {
+	ns, d, check := code[:1], code[1:7], code[7:]
+	if ns != "0" && ns != "1" {
+		return "", false
+	}
+	var body string
+	switch d[5] {
+	case '0', '1', '2':
+		body = d[:2] + d[5:] + "0000" + d[2:5]
+	case '3':
+		body = d[:3] + "00000" + d[3:5]
+	case '4':
+		body = d[:4] + "00000" + d[4:5]
+	default:
+		body = d[:5] + "0000" + d[5:]
+	}
+	return ns + body + check, true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
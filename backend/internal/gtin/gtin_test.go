@@ -0,0 +1,309 @@
+package gtin
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		err  error
+	}{
+		{"4006381333931", "4006381333931", nil},
+		{"4006381333932", "", ErrCheckDigit},
+		{"96385074", "0000096385074", nil},
+		{"036000291452", "0036000291452", nil},    // UPC-A
+		{"00036000291452", "0036000291452", nil},  // GTIN-14, indicator 0
+		{"10036000291459", "10036000291459", nil}, // GTIN-14 of a case
+		{"10036000291450", "", ErrCheckDigit},
+		// Valid only as UPC-E.
+		{"04252614", "0042100005264", nil},
+		// Valid both as EAN-8 and UPC-E: read as EAN-8.
+		{"01234572", "0000001234572", nil},
+		{"00345675", "0000000345675", nil},
+		{"01203462", "0000001203462", nil},
+		{"1234567", "", ErrFormat},
+		{"123456789012345", "", ErrFormat},
+		{"4006381a33931", "", ErrFormat},
+		{"", "", ErrFormat},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.code)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("Normalize(%q) = %q, %v; want %q, %v", tt.code, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestNormalizeAs(t *testing.T) {
+	tests := []struct {
+		code string
+		sym  Symbology
+		want string
+		err  error
+	}{
+		// UPC-E codes that also pass the EAN-8 check get the key of their
+		// UPC-A, the same key as a scan of the UPC-A itself.
+		{"01234572", UPCE, "0012345000072", nil},
+		{"012345000072", UPCA, "0012345000072", nil},
+		{"00345675", UPCE, "0003456000075", nil},
+		{"01203462", UPCE, "0012034000062", nil},
+		{"01234572", EAN8, "0000001234572", nil},
+		{"01234572", "", "0000001234572", nil},
+		{"04252614", UPCE, "0042100005264", nil},
+		{"04252614", EAN8, "", ErrCheckDigit},
+		{"96385074", UPCE, "", ErrSymbology}, // number system 9
+		{"96385074", EAN8, "0000096385074", nil},
+		{"0036000291452", UPCA, "0036000291452", nil},
+		{"4006381333931", UPCA, "", ErrSymbology},
+		{"4006381333931", EAN13, "4006381333931", nil},
+		{"96385074", EAN13, "", ErrSymbology},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeAs(tt.code, tt.sym)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("NormalizeAs(%q, %q) = %q, %v; want %q, %v",
+				tt.code, tt.sym, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestExpandUPCE(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		ok   bool
+	}{
+		{"01234505", "012000003455", true}, // last digit 0-2: manufacturer 5 digits
+		{"01234514", "012100003454", true},
+		{"01234523", "012200003453", true},
+		{"01234531", "012300000451", true}, // 3: manufacturer 3 digits
+		{"01234543", "012340000053", true}, // 4: manufacturer 4 digits
+		{"01234572", "012345000072", true}, // 5-9: item digit
+		{"11234572", "112345000072", true},
+		{"21234572", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := expandUPCE(tt.code)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("expandUPCE(%q) = %q, %v; want %q, %v", tt.code, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	tests := []struct{ key, want string }{
+		{"0000096385074", "96385074"},
+		{"4006381333931", "4006381333931"},
+		{"0036000291452", "0036000291452"},
+		{"10036000291459", "10036000291459"},
+	}
+	for _, tt := range tests {
+		if got := Compact(tt.key); got != tt.want {
+			t.Errorf("Compact(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRestricted(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"2000000000015", true},
+		{"2912345000008", true},
+		{"0000020000004", true}, // EAN-8 prefix 2
+		{"0212345678902", true}, // UPC number system 2
+		{"0412345678900", true}, // UPC number system 4
+		{"4006381333931", false},
+		{"0036000291452", false},
+		{"0000096385074", false},
+		{"20036000291452", false},
+	}
+	for _, tt := range tests {
+		if got := Restricted(tt.key); got != tt.want {
+			t.Errorf("Restricted(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestParseMeasureRules(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []MeasureRule
+	}{
+		{"", nil},
+		{"28-29:PPIIIIIWWWWW", []MeasureRule{{28, 29, "PPIIIIIWWWWW"}}},
+		{
+			" 21-22:PPIIIIICCCCC , 28:PPIIIIXWWWWW,",
+			[]MeasureRule{{21, 22, "PPIIIIICCCCC"}, {28, 28, "PPIIIIXWWWWW"}},
+		},
+		{"23-23:PPIIIIWWWWCC", []MeasureRule{{23, 23, "PPIIIIWWWWCC"}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMeasureRules(tt.in)
+		if err != nil {
+			t.Errorf("ParseMeasureRules(%q): %v", tt.in, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseMeasureRules(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMeasureRulesErrors(t *testing.T) {
+	for _, in := range []string{
+		"28-29",               // no layout
+		"PPIIIIIWWWWW",        // no prefix
+		"8-9:PPIIIIIWWWWW",    // one-digit prefixes
+		"28-2a:PPIIIIIWWWWW",  // not a number
+		"29-28:PPIIIIIWWWWW",  // reversed range
+		"28-290:PPIIIIIWWWWW", // three-digit prefix
+		"20:PPIIIIIWWWWW",     // internal codes
+		"19-21:PPIIIIIWWWWW",  // range including 20
+		"28:PPIIIIIWWWW",      // 11 letters
+		"28:PPIIIIIWWWWWW",    // 13 letters
+		"28:PPIIIIIWWWWQ",     // unknown letter
+		"28:ppiiiiiwwwww",     // lower case
+		"28:PPIIIIIIIIII",     // neither weight nor price
+		"28-29:PPIIIIIWWWWW,29",
+	} {
+		if rules, err := ParseMeasureRules(in); err == nil {
+			t.Errorf("ParseMeasureRules(%q) = %v, want an error", in, rules)
+		}
+	}
+}
+
+func TestDecodeMeasure(t *testing.T) {
+	rules := []MeasureRule{
+		{21, 22, "PPIIIIICCCCC"},
+		{23, 23, "PPIIIIWWWWCC"},
+		{28, 29, "PPIIIIXWWWWW"},
+	}
+	weight := func(g float64) *float64 { return &g }
+	price := func(c int) *int { return &c }
+	tests := []struct {
+		key  string
+		want Measure
+		ok   bool
+	}{
+		// Price in cents; the item code is zeroed and gets a new check digit.
+		{"2112345012346", Measure{Item: "2112345000008", Price: price(1234)}, true},
+		{"2212345000012", Measure{Item: "2212345000005", Price: price(1)}, true},
+		// Weight and price side by side.
+		{"2312340500996", Measure{Item: "2312340000007", Weight: weight(500), Price: price(99)}, true},
+		// The X digit (a price check digit) is not part of the item code.
+		{"2812345012345", Measure{Item: "2812340000002", Weight: weight(1234)}, true},
+		{"2912349000017", Measure{Item: "2912340000009", Weight: weight(1)}, true},
+		// Prefixes no rule covers.
+		{"2012345000001", Measure{}, false},
+		{"2412345012347", Measure{}, false},
+		{"2712345012348", Measure{}, false},
+		{"3012345012348", Measure{}, false},
+		{"4006381333931", Measure{}, false},
+		// Not a GTIN-13 key.
+		{"0000096385074", Measure{}, false},
+		{"28123450123", Measure{}, false},
+		{"10036000291459", Measure{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := DecodeMeasure(tt.key, rules)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DecodeMeasure(%q) = %s, %v; want %s, %v",
+				tt.key, formatMeasure(got), ok, formatMeasure(tt.want), tt.ok)
+		}
+		if ok && !Valid(got.Item) {
+			t.Errorf("DecodeMeasure(%q): item %s has a wrong check digit", tt.key, got.Item)
+		}
+	}
+}
+
+func TestDecodeMeasureDocumentedRule(t *testing.T) {
+	rules, err := ParseMeasureRules("28-29:PPIIIIIWWWWW")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := DecodeMeasure("2812345012345", rules)
+	if !ok || m.Item != "2812345000007" || m.Weight == nil || *m.Weight != 1234 || m.Price != nil {
+		t.Errorf("DecodeMeasure(2812345012345) = %s, %v; want item 2812345000007 weighing 1234 g",
+			formatMeasure(m), ok)
+	}
+
+	// All labels of one item share its base item code.
+	other, _ := DecodeMeasure("2812345004562", rules)
+	if other.Item != m.Item {
+		t.Errorf("labels of one item have item codes %s and %s", m.Item, other.Item)
+	}
+}
+
+func formatMeasure(m Measure) string {
+	s := "{" + m.Item
+	if m.Weight != nil {
+		s += fmt.Sprintf(" %vg", *m.Weight)
+	}
+	if m.Price != nil {
+		s += fmt.Sprintf(" %dc", *m.Price)
+	}
+	return s + "}"
+}
+
+func TestInternal(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{1, "2000000000015"},
+		{42, "2000000000428"},
+		{9999999999, "2099999999998"},
+	}
+	for _, tt := range tests {
+		got := Internal(tt.n)
+		if got != tt.want {
+			t.Errorf("Internal(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+		if key, err := Normalize(got); key != got || err != nil {
+			t.Errorf("Normalize(Internal(%d)) = %q, %v; want the code itself", tt.n, key, err)
+		}
+		if !Restricted(got) {
+			t.Errorf("Internal(%d) = %q is not restricted", tt.n, got)
+		}
+	}
+}
+
+func TestRestrictedBoundaries(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		// The restricted-circulation range of EAN-13 is prefixes 20 to 29.
+		{"1912345678904", false},
+		{"2000000000015", true},
+		{"2999999999992", true},
+		{"3000000000017", false},
+		// Padded EAN-8: codes starting with 2.
+		{"0000019999992", false},
+		{"0000020000004", true},
+		{"0000029999999", true},
+		{"0000030000005", false},
+		// UPC-A: number systems 2 and 4 only.
+		{"0112345678905", false},
+		{"0312345678901", false},
+		{"0512345678906", false},
+		// Not a 13-digit key.
+		{"20000000", false},
+		{"200000000001", false},
+		{"20000000000015", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Restricted(tt.key); got != tt.want {
+			t.Errorf("Restricted(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package gtin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MeasureRule describes the variable-measure codes of a range of two-digit
+// prefixes, such as the labels printed by supermarket scales. Layout has one
+// letter for each of the first 12 digits of the GTIN-13 (the check digit
+// follows):
+//
+//	P, I  prefix and item number, kept in the base item code
+//	W     embedded weight in grams
+//	C     embedded price in minor currency units (cents)
+//	X     ignored, e.g. a check digit over the price
+//
+// For example 28-29:PPIIIIIWWWWW reads 2812345012345 as item 2812345000007
+// weighing 1234 g.
+type MeasureRule struct {
+	From, To int
+	Layout   string
+}
+
+// Measure is a decoded variable-measure code. Item is the base item code:
+// the code with the embedded value zeroed and the check digit recomputed, so
+// all labels of one item share it. Weight (grams) and Price (cents) are set
+// when the layout embeds them.
+type Measure struct {
+	Item   string
+	Weight *float64
+	Price  *int
+}
+
+// ParseMeasureRules parses a comma-separated list of rules of the form
+// FROM[-TO]:LAYOUT, e.g. "21-22:PPIIIIICCCCC,28-29:PPIIIIIWWWWW". The
+// prefix 20 is reserved for internal codes and cannot be used.
+func ParseMeasureRules(s string) ([]MeasureRule, error) {
+	var rules []MeasureRule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		prefixes, layout, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("rule %q: expected PREFIX[-PREFIX]:LAYOUT", part)
+		}
+		fromStr, toStr, isRange := strings.Cut(prefixes, "-")
+		if !isRange {
+			toStr = fromStr
+		}
+		from, err1 := parsePrefix(fromStr)
+		to, err2 := parsePrefix(toStr)
+		if err1 != nil || err2 != nil || from > to {
+			return nil, fmt.Errorf("rule %q: prefixes must be a two-digit range such as 28-29", part)
+		}
+		if from <= internalRange && internalRange <= to {
+			return nil, fmt.Errorf("rule %q: prefix %d is reserved for internal codes", part, internalRange)
+		}
+		if err := checkLayout(layout); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", part, err)
+		}
+		rules = append(rules, MeasureRule{From: from, To: to, Layout: layout})
+	}
+	return rules, nil
+}
+
+// internalRange is the two-digit prefix of the codes assigned by Internal.
+const internalRange = 20
+
+func parsePrefix(s string) (int, error) {
+	if len(s) != 2 {
+		return 0, fmt.Errorf("invalid prefix %q", s)
+	}
+	return strconv.Atoi(s)
+}
+
+func checkLayout(layout string) error {
+	if len(layout) != 12 {
+		return fmt.Errorf("layout must have 12 letters, got %d", len(layout))
+	}
+	if strings.Trim(layout, "PIWCX") != "" {
+		return fmt.Errorf("layout may only contain P, I, W, C and X")
+	}
+	if !strings.ContainsAny(layout, "WC") {
+		return fmt.Errorf("layout must embed a weight (W) or price (C)")
+	}
+	return nil
+}
+
+// DecodeMeasure decodes the canonical key with the first rule whose prefix
+// range contains it. It reports false when no rule applies.
+func DecodeMeasure(key string, rules []MeasureRule) (Measure, bool) {
+	if len(key) != 13 || !isDigits(key) {
+		return Measure{}, false
+	}
+	prefix := int(key[0]-'0')*10 + int(key[1]-'0')
+	for _, r := range rules {
+		if prefix < r.From || prefix > r.To {
+			continue
+		}
+		item := []byte(key[:12])
+		var weight, price string
+		for i := range 12 {
+			switch r.Layout[i] {
+			case 'W':
+				weight += key[i : i+1]
+			case 'C':
+				price += key[i : i+1]
+			case 'X':
+			default:
+				continue
+			}
+			item[i] = '0'
+		}
+
+		m := Measure{Item: string(item) + string(CheckDigit(string(item)))}
+		if weight != "" {
+			w, _ := strconv.ParseFloat(weight, 64)
+			m.Weight = &w
+		}
+		if price != "" {
+			p, _ := strconv.Atoi(price)
+			m.Price = &p
+		}
+		return m, true
+	}
+	return Measure{}, false
+}
@@ -2,17 +2,71 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
-	"regexp"
+	"strconv"
+	"time"
 
+	"foodinventory/internal/gtin"
 	"foodinventory/internal/model"
 )
 
-// eanPattern matches EAN-8 (8 digits) and EAN-13 (13 digits).
-var eanPattern = regexp.MustCompile(`^\d{8}(\d{5})?$`)
+// normalizeEAN verifies the check digit of a scanned barcode and returns its
+// canonical key (see gtin.Normalize). On error it writes a 422 INVALID_EAN
+// response and returns false.
+func normalizeEAN(w http.ResponseWriter, code string) (string, bool) {
+	return normalizeScan(w, code, "")
+}
+
+// normalizeScan is like normalizeEAN for a code scanned from the given
+// symbology, the scanner's format hint (see gtin.NormalizeAs). An unknown
+// format is rejected with 422 INVALID_FORMAT.
+func normalizeScan(w http.ResponseWriter, code string, format gtin.Symbology) (string, bool) {
+	if format != "" && !format.Valid() {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_FORMAT",
+			"format must be one of ean_8, upc_e, upc_a, ean_13")
+		return "", false
+	}
+	ean, err := gtin.NormalizeAs(code, format)
+	if errors.Is(err, gtin.ErrSymbology) {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_EAN",
+			"EAN does not match the barcode format "+string(format))
+		return "", false
+	}
+	if errors.Is(err, gtin.ErrCheckDigit) {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_EAN",
+			"EAN check digit does not match")
+		return "", false
+	}
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_EAN",
+			"EAN must be 8, 12, 13 or 14 digits")
+		return "", false
+	}
+	return ean, true
+}
+
+// validateDate reports whether s is a calendar date in YYYY-MM-DD format.
+func validateDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
 
-func validateEAN(ean string) bool {
-	return eanPattern.MatchString(ean)
+// parseID parses a positive integer path value such as {id}.
+func parseID(s string) (int, bool) {
+	id, err := strconv.Atoi(s)
+	return id, err == nil && id > 0
+}
+
+// decodeOptionalJSON decodes the request body into v. An empty body is not an
+// error and leaves v unchanged, so clients may omit optional bodies.
+func decodeOptionalJSON(r *http.Request, v any) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -24,3 +78,8 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	writeJSON(w, status, model.APIError{Code: code, Message: message})
 }
+
+// writeErrorDetails is writeError with structured details in the body.
+func writeErrorDetails(w http.ResponseWriter, status int, code, message string, details any) {
+	writeJSON(w, status, model.APIError{Code: code, Message: message, Details: details})
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"foodinventory/internal/model"
+	"foodinventory/internal/service"
+)
+
+// RegisterGroups wires product group endpoints onto mux.
+func RegisterGroups(mux *http.ServeMux, svc *service.GroupService) {
+	mux.HandleFunc("GET /api/groups", listGroups(svc))
+	mux.HandleFunc("POST /api/groups", createGroup(svc))
+	mux.HandleFunc("PATCH /api/groups/{id}", updateGroup(svc))
+	mux.HandleFunc("DELETE /api/groups/{id}", deleteGroup(svc))
+	mux.HandleFunc("PUT /api/groups/{id}/members/{ean}", addGroupMember(svc))
+	mux.HandleFunc("DELETE /api/groups/{id}/members/{ean}", removeGroupMember(svc))
+}
+
+func listGroups(svc *service.GroupService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groups, err := svc.List(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, groups)
+	}
+}
+
+func createGroup(svc *service.GroupService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeGroupRequest(w, r)
+		if !ok {
+			return
+		}
+
+		group, err := svc.Create(r.Context(), req)
+		if errors.Is(err, service.ErrGroupExists) {
+			writeError(w, http.StatusConflict, "GROUP_EXISTS",
+				"A group named "+req.Name+" already exists")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, group)
+	}
+}
+
+func updateGroup(svc *service.GroupService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"id must be a positive integer")
+			return
+		}
+		req, ok := decodeGroupRequest(w, r)
+		if !ok {
+			return
+		}
+
+		group, err := svc.Update(r.Context(), id, req)
+		switch {
+		case errors.Is(err, service.ErrGroupNotFound):
+			writeError(w, http.StatusNotFound, "GROUP_NOT_FOUND",
+				"No group with ID "+r.PathValue("id"))
+		case errors.Is(err, service.ErrGroupExists):
+			writeError(w, http.StatusConflict, "GROUP_EXISTS",
+				"A group named "+req.Name+" already exists")
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		default:
+			writeJSON(w, http.StatusOK, group)
+		}
+	}
+}
+
+func deleteGroup(svc *service.GroupService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"id must be a positive integer")
+			return
+		}
+
+		err := svc.Delete(r.Context(), id)
+		switch {
+		case errors.Is(err, service.ErrGroupNotFound):
+			writeError(w, http.StatusNotFound, "GROUP_NOT_FOUND",
+				"No group with ID "+r.PathValue("id"))
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func addGroupMember(svc *service.GroupService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"id must be a positive integer")
+			return
+		}
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		group, err := svc.AddMember(r.Context(), id, ean)
+		switch {
+		case errors.Is(err, service.ErrGroupNotFound):
+			writeError(w, http.StatusNotFound, "GROUP_NOT_FOUND",
+				"No group with ID "+r.PathValue("id"))
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		default:
+			writeJSON(w, http.StatusOK, group)
+		}
+	}
+}
+
+func removeGroupMember(svc *service.GroupService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"id must be a positive integer")
+			return
+		}
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		err := svc.RemoveMember(r.Context(), id, ean)
+		switch {
+		case errors.Is(err, service.ErrNotGroupMember):
+			writeError(w, http.StatusNotFound, "GROUP_MEMBER_NOT_FOUND",
+				"Product "+ean+" is not a member of group "+r.PathValue("id"))
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// decodeGroupRequest decodes and validates a group body, writing the error
+// response itself. It reports whether the handler should continue.
+func decodeGroupRequest(w http.ResponseWriter, r *http.Request) (model.GroupRequest, bool) {
+	var req model.GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_GROUP",
+			"name must not be empty")
+		return req, false
+	}
+	if req.MinQuantity < 1 {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_GROUP",
+			"min_quantity must be >= 1")
+		return req, false
+	}
+	return req, true
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"foodinventory/internal/model"
+	"foodinventory/internal/service"
+)
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// RegisterHistory wires the stock movement ledger endpoints onto mux.
+//
+//	GET /api/inventory/history       — events for all products
+//	GET /api/products/{ean}/history  — events for one product
+//
+// Both accept the query parameters from, to (RFC 3339 timestamp or
+// YYYY-MM-DD date; a date in "to" includes that whole day) and limit.
+func RegisterHistory(mux *http.ServeMux, svc *service.HistoryService) {
+	mux.HandleFunc("GET /api/inventory/history", listHistory(svc))
+	mux.HandleFunc("GET /api/products/{ean}/history", listProductHistory(svc))
+}
+
+func listHistory(svc *service.HistoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseHistoryFilter(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_FILTER", err.Error())
+			return
+		}
+		writeHistory(w, r, svc, filter)
+	}
+}
+
+func listProductHistory(svc *service.HistoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+		filter, err := parseHistoryFilter(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_FILTER", err.Error())
+			return
+		}
+		filter.EAN = &ean
+		writeHistory(w, r, svc, filter)
+	}
+}
+
+func writeHistory(w http.ResponseWriter, r *http.Request, svc *service.HistoryService, filter model.HistoryFilter) {
+	events, err := svc.List(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// parseHistoryFilter reads the from, to and limit query parameters.
+func parseHistoryFilter(q url.Values) (model.HistoryFilter, error) {
+	f := model.HistoryFilter{Limit: defaultHistoryLimit}
+
+	from, to, err := parsePeriod(q)
+	if err != nil {
+		return f, err
+	}
+	f.From, f.To = from, to
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxHistoryLimit {
+			return f, fmt.Errorf("limit must be between 1 and %d", maxHistoryLimit)
+		}
+		f.Limit = limit
+	}
+	return f, nil
+}
+
+// parsePeriod reads the optional from and to query parameters. A date in
+// "to" includes that whole day, so the returned period is [from, to).
+func parsePeriod(q url.Values) (from, to *time.Time, err error) {
+	if v := q.Get("from"); v != "" {
+		t, _, err := parseTimeParam(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("from: %w", err)
+		}
+		from = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, dateOnly, err := parseTimeParam(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("to: %w", err)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1) // include the whole day
+		}
+		to = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or a YYYY-MM-DD date (UTC
+// midnight) and reports whether the value was a plain date.
+func parseTimeParam(v string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("expected RFC 3339 timestamp or YYYY-MM-DD date, got %q", v)
+	}
+	return t, false, nil
+}
@@ -3,8 +3,13 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"foodinventory/internal/gs1"
+	"foodinventory/internal/gtin"
 	"foodinventory/internal/model"
 	"foodinventory/internal/service"
 )
@@ -14,6 +19,12 @@ func RegisterInventory(mux *http.ServeMux, svc *service.InventoryService) {
 	mux.HandleFunc("GET /api/inventory", listInventory(svc))
 	mux.HandleFunc("POST /api/inventory", addProduct(svc))
 	mux.HandleFunc("DELETE /api/inventory/{ean}", removeProduct(svc))
+	mux.HandleFunc("PATCH /api/inventory/{id}", updateEntry(svc))
+	mux.HandleFunc("PUT /api/inventory/{ean}/quantity", setQuantity(svc))
+	mux.HandleFunc("POST /api/inventory/{ean}/move", moveProduct(svc))
+	mux.HandleFunc("POST /api/inventory/{id}/open", openEntry(svc))
+	mux.HandleFunc("POST /api/inventory/undo", undoLast(svc))
+	mux.HandleFunc("POST /api/inventory/operations/{operationID}/undo", undoOperation(svc))
 }
 
 func listInventory(svc *service.InventoryService) http.HandlerFunc {
@@ -34,13 +45,45 @@ func addProduct(svc *service.InventoryService) http.HandlerFunc {
 			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
 			return
 		}
-		if !validateEAN(req.EAN) {
-			writeError(w, http.StatusUnprocessableEntity, "INVALID_EAN",
-				"EAN must be 8 or 13 digits")
+		if req.Code != nil && !applyScanCode(w, &req) {
+			return
+		}
+		var format gtin.Symbology
+		if req.Format != nil {
+			format = gtin.Symbology(*req.Format)
+		}
+		ean, ok := normalizeScan(w, req.EAN, format)
+		if !ok {
+			return
+		}
+		req.EAN = ean
+		if req.Batch != nil {
+			batch := strings.TrimSpace(*req.Batch)
+			req.Batch = &batch
+			if batch == "" {
+				req.Batch = nil
+			}
+		}
+		if req.ExpiryDate != nil && !validateDate(*req.ExpiryDate) {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_EXPIRY_DATE",
+				"expiry_date must be a date in YYYY-MM-DD format")
+			return
+		}
+		if req.Quantity != nil && *req.Quantity < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_QUANTITY",
+				"quantity must be >= 1")
 			return
 		}
 
 		entry, created, err := svc.Add(r.Context(), req)
+		if errors.Is(err, service.ErrLocationNotFound) {
+			writeLocationNotFound(w, req.LocationID)
+			return
+		}
+		if errors.Is(err, service.ErrPackTooDeep) {
+			writePackTooDeep(w, ean)
+			return
+		}
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
 			return
@@ -56,19 +99,59 @@ func addProduct(svc *service.InventoryService) http.HandlerFunc {
 
 func removeProduct(svc *service.InventoryService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ean := r.PathValue("ean")
-		if !validateEAN(ean) {
-			writeError(w, http.StatusUnprocessableEntity, "INVALID_EAN",
-				"EAN must be 8 or 13 digits")
+		format := gtin.Symbology(r.URL.Query().Get("format"))
+		ean, ok := normalizeScan(w, r.PathValue("ean"), format)
+		if !ok {
 			return
 		}
 
-		entry, err := svc.Remove(r.Context(), ean)
+		var req model.RemoveProductRequest
+		if err := decodeOptionalJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if req.Quantity != nil && *req.Quantity < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_QUANTITY",
+				"quantity must be >= 1")
+			return
+		}
+		if req.Quantity != nil && req.Amount != nil {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_QUANTITY",
+				"quantity and amount are mutually exclusive")
+			return
+		}
+		if !validateAmount(w, req.Amount, req.Unit) {
+			return
+		}
+		if req.Reason != nil && !req.Reason.Valid() {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_REASON",
+				"reason must be one of consumed, expired, spoiled, donated, other")
+			return
+		}
+
+		entry, err := svc.Remove(r.Context(), ean, req)
+		if errors.Is(err, service.ErrLocationNotFound) {
+			writeLocationNotFound(w, req.LocationID)
+			return
+		}
+		if errors.Is(err, service.ErrPackTooDeep) {
+			writePackTooDeep(w, ean)
+			return
+		}
 		if errors.Is(err, service.ErrInventoryEntryNotFound) {
 			writeError(w, http.StatusNotFound, "INVENTORY_ENTRY_NOT_FOUND",
 				"No inventory entry for EAN "+ean)
 			return
 		}
+		if errors.Is(err, service.ErrInsufficientStock) {
+			writeError(w, http.StatusConflict, "INSUFFICIENT_STOCK",
+				"Not enough units of EAN "+ean+" in stock")
+			return
+		}
+		if errors.Is(err, service.ErrIncompatibleUnit) {
+			writeIncompatibleUnit(w)
+			return
+		}
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
 			return
@@ -82,3 +165,300 @@ func removeProduct(svc *service.InventoryService) http.HandlerFunc {
 		writeJSON(w, http.StatusOK, entry)
 	}
 }
+
+func updateEntry(svc *service.InventoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"id must be a positive integer")
+			return
+		}
+
+		var req model.UpdateInventoryEntryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if !req.ExpiryDate.Set && req.LowStockThreshold == nil && req.Amount == nil {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ENTRY",
+				"at least one of expiry_date, low_stock_threshold, amount must be given")
+			return
+		}
+		if req.ExpiryDate.Value != nil && !validateDate(*req.ExpiryDate.Value) {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_EXPIRY_DATE",
+				"expiry_date must be a date in YYYY-MM-DD format or null")
+			return
+		}
+		if req.LowStockThreshold != nil && *req.LowStockThreshold < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ENTRY",
+				"low_stock_threshold must be >= 1")
+			return
+		}
+		if !validateAmount(w, req.Amount, req.Unit) {
+			return
+		}
+
+		entry, err := svc.UpdateEntry(r.Context(), id, req)
+		if errors.Is(err, service.ErrInventoryEntryNotFound) {
+			writeError(w, http.StatusNotFound, "INVENTORY_ENTRY_NOT_FOUND",
+				"No inventory entry with ID "+r.PathValue("id"))
+			return
+		}
+		if errors.Is(err, service.ErrIncompatibleUnit) {
+			writeIncompatibleUnit(w)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, entry)
+	}
+}
+
+func setQuantity(svc *service.InventoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		var req model.SetQuantityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if req.Quantity == nil || *req.Quantity < 0 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_QUANTITY",
+				"quantity is required and must be >= 0")
+			return
+		}
+
+		entries, err := svc.SetQuantity(r.Context(), ean, req)
+		if errors.Is(err, service.ErrLocationNotFound) {
+			writeLocationNotFound(w, req.LocationID)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+func moveProduct(svc *service.InventoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		var req model.MoveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if req.Quantity < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_MOVE",
+				"quantity must be >= 1")
+			return
+		}
+		if sameLocation(req.FromLocationID, req.ToLocationID) {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_MOVE",
+				"from_location_id and to_location_id must differ")
+			return
+		}
+
+		entries, err := svc.Move(r.Context(), ean, req)
+		switch {
+		case errors.Is(err, service.ErrLocationNotFound):
+			writeError(w, http.StatusUnprocessableEntity, "LOCATION_NOT_FOUND",
+				"from_location_id or to_location_id does not exist")
+		case errors.Is(err, service.ErrInventoryEntryNotFound):
+			writeError(w, http.StatusNotFound, "INVENTORY_ENTRY_NOT_FOUND",
+				"No inventory entry for EAN "+ean+" at the source location")
+		case errors.Is(err, service.ErrInsufficientStock):
+			writeError(w, http.StatusConflict, "INSUFFICIENT_STOCK",
+				"Not enough units of EAN "+ean+" at the source location")
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		default:
+			writeJSON(w, http.StatusOK, entries)
+		}
+	}
+}
+
+func openEntry(svc *service.InventoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"id must be a positive integer")
+			return
+		}
+
+		entry, err := svc.Open(r.Context(), id)
+		if errors.Is(err, service.ErrInventoryEntryNotFound) {
+			writeError(w, http.StatusNotFound, "INVENTORY_ENTRY_NOT_FOUND",
+				"No inventory entry with ID "+r.PathValue("id"))
+			return
+		}
+		if errors.Is(err, service.ErrAlreadyOpened) {
+			writeError(w, http.StatusConflict, "ALREADY_OPENED",
+				"Inventory entry "+r.PathValue("id")+" is already opened")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, entry)
+	}
+}
+
+func sameLocation(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// applyScanCode fills req from the raw scan in req.Code. A plain barcode
+// becomes the EAN; anything else is parsed as a GS1 element string, which must
+// carry a GTIN (AI 01) and may supply the expiry date (AI 17, else the
+// best-before date AI 15) and batch (AI 10) unless they were given. On error
+// it writes a 422 response and returns false.
+func applyScanCode(w http.ResponseWriter, req *model.AddProductRequest) bool {
+	if req.EAN != "" {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_CODE",
+			"ean and code are mutually exclusive")
+		return false
+	}
+	code := strings.TrimSpace(*req.Code)
+	if isPlainBarcode(code) {
+		req.EAN = code
+		return true
+	}
+
+	es, err := gs1.Parse(code)
+	var perr *gs1.ParseError
+	if errors.As(err, &perr) {
+		writeErrorDetails(w, http.StatusUnprocessableEntity, "INVALID_GS1",
+			"code is not a valid GS1 element string: "+perr.Reason, perr)
+		return false
+	}
+	ean, ok := es.Get(gs1.AIGTIN)
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_GS1",
+			"code has no GTIN (AI 01)")
+		return false
+	}
+	req.EAN = ean
+	req.Format = nil // a GTIN-14 field, whatever the symbology
+	if req.ExpiryDate == nil {
+		date, ok := es.Date(gs1.AIExpiry)
+		if !ok {
+			date, ok = es.Date(gs1.AIBestBefore)
+		}
+		if ok {
+			req.ExpiryDate = &date
+		}
+	}
+	if batch, ok := es.Get(gs1.AIBatch); ok && req.Batch == nil {
+		req.Batch = &batch
+	}
+	return true
+}
+
+// isPlainBarcode reports whether code looks like a linear barcode (8, 12, 13
+// or 14 digits) rather than a GS1 element string.
+func isPlainBarcode(code string) bool {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLocationNotFound(w http.ResponseWriter, id *int) {
+	writeError(w, http.StatusUnprocessableEntity, "LOCATION_NOT_FOUND",
+		fmt.Sprintf("No location with ID %d", *id))
+}
+
+// validateAmount checks an optional amount and its unit, writing a 422 and
+// returning false when they are invalid.
+func validateAmount(w http.ResponseWriter, amount *float64, unit *model.Unit) bool {
+	if amount != nil && *amount <= 0 {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_AMOUNT",
+			"amount must be > 0")
+		return false
+	}
+	if unit != nil && !unit.Valid() {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_UNIT",
+			"unit must be one of pcs, g, kg, ml, l")
+		return false
+	}
+	return true
+}
+
+func writeIncompatibleUnit(w http.ResponseWriter) {
+	writeError(w, http.StatusUnprocessableEntity, "INCOMPATIBLE_UNIT",
+		"unit is not compatible with the product's unit")
+}
+
+func writePackTooDeep(w http.ResponseWriter, ean string) {
+	writeError(w, http.StatusUnprocessableEntity, "INVALID_CONTENTS",
+		"packages of EAN "+ean+" are nested too deeply or contain themselves")
+}
+
+func undoLast(svc *service.InventoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := svc.UndoLast(r.Context())
+		writeUndoResult(w, result, err)
+	}
+}
+
+func undoOperation(svc *service.InventoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opID, err := strconv.ParseInt(r.PathValue("operationID"), 10, 64)
+		if err != nil || opID < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"operation ID must be a positive integer")
+			return
+		}
+		result, err := svc.Undo(r.Context(), opID)
+		writeUndoResult(w, result, err)
+	}
+}
+
+func writeUndoResult(w http.ResponseWriter, result *model.UndoResult, err error) {
+	switch {
+	case errors.Is(err, service.ErrNothingToUndo):
+		writeError(w, http.StatusNotFound, "NOTHING_TO_UNDO",
+			"No operation within the undo window")
+	case errors.Is(err, service.ErrOperationNotFound):
+		writeError(w, http.StatusNotFound, "OPERATION_NOT_FOUND", err.Error())
+	case errors.Is(err, service.ErrNotUndoable):
+		writeError(w, http.StatusConflict, "NOT_UNDOABLE",
+			"Only add, remove, move, set-quantity and open operations can be undone")
+	case errors.Is(err, service.ErrAlreadyUndone):
+		writeError(w, http.StatusConflict, "ALREADY_UNDONE", err.Error())
+	case errors.Is(err, service.ErrUndoExpired):
+		writeError(w, http.StatusConflict, "UNDO_EXPIRED", err.Error())
+	case errors.Is(err, service.ErrUndoConflict):
+		writeError(w, http.StatusConflict, "UNDO_CONFLICT",
+			"Stock changed since the operation; it can no longer be reverted exactly")
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+	default:
+		writeJSON(w, http.StatusOK, result)
+	}
+}
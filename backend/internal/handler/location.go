@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"foodinventory/internal/model"
+	"foodinventory/internal/service"
+)
+
+// RegisterLocations wires storage location endpoints onto mux.
+func RegisterLocations(mux *http.ServeMux, svc *service.LocationService) {
+	mux.HandleFunc("GET /api/locations", listLocations(svc))
+	mux.HandleFunc("POST /api/locations", createLocation(svc))
+	mux.HandleFunc("PATCH /api/locations/{id}", renameLocation(svc))
+	mux.HandleFunc("DELETE /api/locations/{id}", deleteLocation(svc))
+}
+
+func listLocations(svc *service.LocationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locations, err := svc.List(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, locations)
+	}
+}
+
+func createLocation(svc *service.LocationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeLocationRequest(w, r)
+		if !ok {
+			return
+		}
+
+		location, err := svc.Create(r.Context(), req.Name)
+		if errors.Is(err, service.ErrLocationExists) {
+			writeError(w, http.StatusConflict, "LOCATION_EXISTS",
+				"A location named "+req.Name+" already exists")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, location)
+	}
+}
+
+func renameLocation(svc *service.LocationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"id must be a positive integer")
+			return
+		}
+		req, ok := decodeLocationRequest(w, r)
+		if !ok {
+			return
+		}
+
+		location, err := svc.Rename(r.Context(), id, req.Name)
+		switch {
+		case errors.Is(err, service.ErrLocationNotFound):
+			writeError(w, http.StatusNotFound, "LOCATION_NOT_FOUND",
+				"No location with ID "+r.PathValue("id"))
+		case errors.Is(err, service.ErrLocationExists):
+			writeError(w, http.StatusConflict, "LOCATION_EXISTS",
+				"A location named "+req.Name+" already exists")
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		default:
+			writeJSON(w, http.StatusOK, location)
+		}
+	}
+}
+
+func deleteLocation(svc *service.LocationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"id must be a positive integer")
+			return
+		}
+
+		err := svc.Delete(r.Context(), id)
+		switch {
+		case errors.Is(err, service.ErrLocationNotFound):
+			writeError(w, http.StatusNotFound, "LOCATION_NOT_FOUND",
+				"No location with ID "+r.PathValue("id"))
+		case errors.Is(err, service.ErrLocationInUse):
+			writeError(w, http.StatusConflict, "LOCATION_IN_USE",
+				"Location still holds inventory; move or remove it first")
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// decodeLocationRequest decodes and validates a location body, writing the
+// error response itself. It reports whether the handler should continue.
+func decodeLocationRequest(w http.ResponseWriter, r *http.Request) (model.LocationRequest, bool) {
+	var req model.LocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_LOCATION",
+			"name must not be empty")
+		return req, false
+	}
+	return req, true
+}
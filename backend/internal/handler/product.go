@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"foodinventory/internal/model"
@@ -10,15 +11,81 @@ import (
 
 // RegisterProduct wires product endpoints onto mux.
 func RegisterProduct(mux *http.ServeMux, svc *service.ProductService) {
+	mux.HandleFunc("POST /api/products", createProduct(svc))
+	mux.HandleFunc("GET /api/products/{ean}", getProduct(svc))
 	mux.HandleFunc("PATCH /api/products/{ean}", updateProduct(svc))
+	mux.HandleFunc("GET /api/products/watched", listWatched(svc))
+	mux.HandleFunc("PUT /api/products/{ean}/watch", watchProduct(svc))
+	mux.HandleFunc("DELETE /api/products/{ean}/watch", unwatchProduct(svc))
+	mux.HandleFunc("PUT /api/products/{ean}/target-quantity", setTargetQuantity(svc))
+	mux.HandleFunc("PUT /api/products/{ean}/unit", setMeasure(svc))
+	mux.HandleFunc("PUT /api/products/{ean}/days-after-opening", setDaysAfterOpening(svc))
+	mux.HandleFunc("POST /api/products/{ean}/aliases", addAlias(svc))
+	mux.HandleFunc("DELETE /api/products/{ean}/aliases/{alias}", removeAlias(svc))
+	mux.HandleFunc("POST /api/products/{ean}/merge", mergeProducts(svc))
+	mux.HandleFunc("PUT /api/products/{ean}/contents", setContents(svc))
+	mux.HandleFunc("DELETE /api/products/{ean}/contents", clearContents(svc))
+}
+
+func getProduct(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		product, err := svc.Product(r.Context(), ean)
+		if errors.Is(err, service.ErrProductNotFound) {
+			writeError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+				"No product with EAN "+ean)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, product)
+	}
+}
+
+func createProduct(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req model.CreateProductRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if req.Name == "" {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_PRODUCT",
+				"name must not be empty")
+			return
+		}
+		unit := model.UnitPieces
+		if req.Unit != nil {
+			unit = *req.Unit
+		}
+		if !validateMeasure(w, unit, req.PackageSize, nil) {
+			return
+		}
+		if req.DaysAfterOpening != nil && *req.DaysAfterOpening < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_DAYS_AFTER_OPENING",
+				"days_after_opening must be >= 1")
+			return
+		}
+
+		product, err := svc.Create(r.Context(), req)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, product)
+	}
 }
 
 func updateProduct(svc *service.ProductService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ean := r.PathValue("ean")
-		if !validateEAN(ean) {
-			writeError(w, http.StatusUnprocessableEntity, "INVALID_EAN",
-				"EAN must be 8 or 13 digits")
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
 			return
 		}
 
@@ -33,7 +100,371 @@ func updateProduct(svc *service.ProductService) http.HandlerFunc {
 			return
 		}
 
-		if err := svc.UpdateProduct(r.Context(), ean, req.Name, req.Category); err != nil {
+		err := svc.UpdateProduct(r.Context(), ean, req.Name, req.Category)
+		if errors.Is(err, service.ErrProductNotFound) {
+			writeError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+				"No product with EAN "+ean)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func listWatched(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		watched, err := svc.ListWatched(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, watched)
+	}
+}
+
+func watchProduct(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		var req model.WatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if req.MinQuantity < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_WATCH",
+				"min_quantity must be >= 1")
+			return
+		}
+
+		product, err := svc.Watch(r.Context(), ean, req.MinQuantity)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, product)
+	}
+}
+
+func unwatchProduct(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		err := svc.Unwatch(r.Context(), ean)
+		if errors.Is(err, service.ErrProductNotFound) {
+			writeError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+				"No product with EAN "+ean)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func setTargetQuantity(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		var req model.TargetQuantityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if req.TargetQuantity != nil && *req.TargetQuantity < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_TARGET_QUANTITY",
+				"target_quantity must be >= 1")
+			return
+		}
+
+		product, err := svc.SetTargetQuantity(r.Context(), ean, req.TargetQuantity)
+		if errors.Is(err, service.ErrProductNotFound) {
+			writeError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+				"No product with EAN "+ean)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, product)
+	}
+}
+
+func setMeasure(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		var req model.MeasureRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if !validateMeasure(w, req.Unit, req.PackageSize, req.LowStockAmount) {
+			return
+		}
+
+		product, err := svc.SetMeasure(r.Context(), ean, req)
+		if errors.Is(err, service.ErrProductNotFound) {
+			writeError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+				"No product with EAN "+ean)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, product)
+	}
+}
+
+func setDaysAfterOpening(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		var req model.DaysAfterOpeningRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if req.DaysAfterOpening != nil && *req.DaysAfterOpening < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_DAYS_AFTER_OPENING",
+				"days_after_opening must be >= 1")
+			return
+		}
+
+		product, err := svc.SetDaysAfterOpening(r.Context(), ean, req.DaysAfterOpening)
+		if errors.Is(err, service.ErrProductNotFound) {
+			writeError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+				"No product with EAN "+ean)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, product)
+	}
+}
+
+func addAlias(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		var req model.AliasRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		alias, ok := normalizeEAN(w, req.Alias)
+		if !ok {
+			return
+		}
+		if alias == ean {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ALIAS",
+				"a product cannot be an alias of itself")
+			return
+		}
+
+		product, err := svc.AddAlias(r.Context(), ean, alias)
+		if errors.Is(err, service.ErrProductNotFound) {
+			writeError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+				"No product with EAN "+ean)
+			return
+		}
+		if errors.Is(err, service.ErrAliasConflict) {
+			writeError(w, http.StatusConflict, "ALIAS_CONFLICT",
+				"EAN "+alias+" is a product of its own or an alias of another product; merge the products instead")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, product)
+	}
+}
+
+func removeAlias(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+		alias, ok := normalizeEAN(w, r.PathValue("alias"))
+		if !ok {
+			return
+		}
+
+		err := svc.RemoveAlias(r.Context(), ean, alias)
+		if errors.Is(err, service.ErrAliasNotFound) {
+			writeError(w, http.StatusNotFound, "ALIAS_NOT_FOUND",
+				"EAN "+alias+" is not an alias of "+ean)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func mergeProducts(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		var req model.MergeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		from, ok := normalizeEAN(w, req.From)
+		if !ok {
+			return
+		}
+		if from == ean {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_MERGE",
+				"a product cannot be merged into itself")
+			return
+		}
+
+		product, err := svc.Merge(r.Context(), ean, from)
+		if errors.Is(err, service.ErrMergeSelf) {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_MERGE",
+				"EAN "+ean+" and "+from+" are the same product")
+			return
+		}
+		if errors.Is(err, service.ErrProductNotFound) {
+			writeError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+				"No product with EAN "+ean+" or "+from)
+			return
+		}
+		if errors.Is(err, service.ErrUnitMismatch) {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_MERGE",
+				"products measured in different units cannot be merged")
+			return
+		}
+		if errors.Is(err, service.ErrContentsCycle) {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_MERGE",
+				"the merged product would contain itself; clear the contents of "+ean+" or "+from+" first")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, product)
+	}
+}
+
+// validateMeasure checks a unit with its package size and amount-based
+// low-stock threshold, writing a 422 INVALID_UNIT response when they do not
+// fit together. lowStockAmount may be nil.
+func validateMeasure(w http.ResponseWriter, unit model.Unit, packageSize, lowStockAmount *float64) bool {
+	if !unit.Valid() {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_UNIT",
+			"unit must be one of pcs, g, kg, ml, l")
+		return false
+	}
+	if packageSize == nil && unit != model.UnitPieces {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_UNIT",
+			"package_size is required unless unit is pcs")
+		return false
+	}
+	if packageSize != nil && *packageSize <= 0 {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_UNIT",
+			"package_size must be > 0")
+		return false
+	}
+	if lowStockAmount != nil && *lowStockAmount <= 0 {
+		writeError(w, http.StatusUnprocessableEntity, "INVALID_UNIT",
+			"low_stock_amount must be > 0")
+		return false
+	}
+	return true
+}
+
+func setContents(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		var req model.ContentsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		inner, ok := normalizeEAN(w, req.EAN)
+		if !ok {
+			return
+		}
+		if req.Quantity < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_CONTENTS",
+				"quantity must be >= 1")
+			return
+		}
+
+		product, err := svc.SetContents(r.Context(), ean, inner, req.Quantity)
+		if errors.Is(err, service.ErrContentsCycle) {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_CONTENTS",
+				"EAN "+inner+" is or contains "+ean+"; a package cannot contain itself")
+			return
+		}
+		if errors.Is(err, service.ErrPackTooDeep) {
+			writePackTooDeep(w, ean)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, product)
+	}
+}
+
+func clearContents(svc *service.ProductService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ean, ok := normalizeEAN(w, r.PathValue("ean"))
+		if !ok {
+			return
+		}
+
+		err := svc.ClearContents(r.Context(), ean)
+		if errors.Is(err, service.ErrProductNotFound) {
+			writeError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND",
+				"No product with EAN "+ean)
+			return
+		}
+		if err != nil {
 			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
 			return
 		}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"foodinventory/internal/service"
+)
+
+// RegisterReports wires report endpoints onto mux.
+//
+//	GET /api/reports/waste — discarded units by product, category and month
+//
+// The waste report accepts the same from and to query parameters as the
+// history endpoints.
+func RegisterReports(mux *http.ServeMux, svc *service.ReportService) {
+	mux.HandleFunc("GET /api/reports/waste", wasteReport(svc))
+}
+
+func wasteReport(svc *service.ReportService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parsePeriod(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_FILTER", err.Error())
+			return
+		}
+
+		report, err := svc.Waste(r.Context(), from, to)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"foodinventory/internal/model"
 	"foodinventory/internal/service"
 )
 
@@ -27,7 +26,13 @@ func getSettings(svc *service.SettingsService) http.HandlerFunc {
 
 func updateSettings(svc *service.SettingsService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var s model.Settings
+		// Fields absent from the body keep their current values.
+		current, err := svc.Get(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		s := *current
 		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
 			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
 			return
@@ -37,6 +42,11 @@ func updateSettings(svc *service.SettingsService) http.HandlerFunc {
 				"expiry_warning_days must be >= 1")
 			return
 		}
+		if s.DepletionHorizonDays < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_SETTINGS",
+				"depletion_horizon_days must be >= 1")
+			return
+		}
 		updated, err := svc.Update(r.Context(), s)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
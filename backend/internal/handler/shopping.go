@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"foodinventory/internal/model"
+	"foodinventory/internal/service"
+)
+
+// RegisterShoppingList wires shopping list endpoints onto mux.
+func RegisterShoppingList(mux *http.ServeMux, svc *service.ShoppingListService) {
+	mux.HandleFunc("GET /api/shopping-list", listShoppingList(svc))
+	mux.HandleFunc("POST /api/shopping-list", addShoppingItem(svc))
+	mux.HandleFunc("DELETE /api/shopping-list", clearShoppingList(svc))
+	mux.HandleFunc("PATCH /api/shopping-list/{id}", updateShoppingItem(svc))
+	mux.HandleFunc("DELETE /api/shopping-list/{id}", deleteShoppingItem(svc))
+}
+
+func listShoppingList(svc *service.ShoppingListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items, err := svc.List(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, items)
+	}
+}
+
+func addShoppingItem(svc *service.ShoppingListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req model.AddShoppingListItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			req.Name = &name
+			if name == "" {
+				req.Name = nil
+			}
+		}
+		if req.EAN == nil && req.Name == nil {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ITEM",
+				"ean or name is required")
+			return
+		}
+		if req.EAN != nil {
+			ean, ok := normalizeEAN(w, *req.EAN)
+			if !ok {
+				return
+			}
+			req.EAN = &ean
+		}
+		if req.Quantity != nil && *req.Quantity < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_QUANTITY",
+				"quantity must be >= 1")
+			return
+		}
+
+		item, err := svc.Add(r.Context(), req)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, item)
+	}
+}
+
+func updateShoppingItem(svc *service.ShoppingListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"id must be a positive integer")
+			return
+		}
+
+		var req model.UpdateShoppingListItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid request body")
+			return
+		}
+		if req.Quantity != nil && *req.Quantity < 1 {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_QUANTITY",
+				"quantity must be >= 1")
+			return
+		}
+
+		item, err := svc.Update(r.Context(), id, req)
+		if errors.Is(err, service.ErrShoppingItemNotFound) {
+			writeShoppingItemNotFound(w)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	}
+}
+
+func deleteShoppingItem(svc *service.ShoppingListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := parseID(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "INVALID_ID",
+				"id must be a positive integer")
+			return
+		}
+
+		err := svc.Delete(r.Context(), id)
+		if errors.Is(err, service.ErrShoppingItemNotFound) {
+			writeShoppingItemNotFound(w)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// clearShoppingList removes the checked items; ?all=true empties the list.
+func clearShoppingList(svc *service.ShoppingListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		all := r.URL.Query().Get("all") == "true"
+		if err := svc.Clear(r.Context(), all); err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeShoppingItemNotFound(w http.ResponseWriter) {
+	writeError(w, http.StatusNotFound, "SHOPPING_ITEM_NOT_FOUND",
+		"No shopping list item with this id")
+}
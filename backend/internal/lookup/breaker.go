@@ -0,0 +1,84 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"foodinventory/internal/model"
+)
+
+// ErrCircuitOpen is returned by a provider behind a circuit breaker while
+// the breaker considers the source down. No request is sent.
+var ErrCircuitOpen = errors.New("product lookup source unavailable")
+
+// WithBreaker returns a provider that stops asking p after failures
+// consecutive failed lookups and fails at once with ErrCircuitOpen instead,
+// so a known outage does not cost every scan the full timeout. After
+// cooldown a single lookup is let through as a probe: if it succeeds the
+// breaker closes, otherwise it stays open for another cooldown.
+//
+// Any answer from p, including that it does not know the product, counts as
+// success. Rate-limited lookups and lookups cancelled by the caller are not
+// counted at all.
+func WithBreaker(p ProductLookup, failures int, cooldown time.Duration) ProductLookup {
+	return &breaker{provider: p, failures: max(failures, 1), cooldown: cooldown}
+}
+
+type breaker struct {
+	provider ProductLookup
+	failures int
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	failed    int       // consecutive failures
+	openUntil time.Time // zero while closed
+	probing   bool      // a probe is in flight
+}
+
+func (b *breaker) Lookup(ctx context.Context, ean string) (*model.Product, error) {
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+	p, err := b.provider.Lookup(ctx, ean)
+	b.record(ctx, err)
+	return p, err
+}
+
+// allow reports whether a lookup may be sent: always while the breaker is
+// closed, and once the cooldown has passed for a single probe.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record updates the breaker with the outcome of a lookup it allowed.
+func (b *breaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := b.probing
+	b.probing = false
+	switch {
+	case err == nil:
+		b.failed = 0
+		b.openUntil = time.Time{}
+	case errors.Is(err, ErrRateLimited) || errors.Is(ctx.Err(), context.Canceled):
+		// Not the source's fault. An interrupted probe is simply retried.
+	default:
+		b.failed++
+		if probe || b.failed >= b.failures {
+			b.openUntil = time.Now().Add(b.cooldown)
+		}
+	}
+}
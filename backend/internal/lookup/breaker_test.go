@@ -0,0 +1,132 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"foodinventory/internal/model"
+)
+
+var errDown = errors.New("source down")
+
+// fakeProvider answers every lookup with err, after waiting for release
+// when it is set, and counts the lookups it got.
+type fakeProvider struct {
+	mu      sync.Mutex
+	err     error
+	release chan struct{}
+	calls   int
+}
+
+func (f *fakeProvider) Lookup(ctx context.Context, ean string) (*model.Product, error) {
+	f.mu.Lock()
+	f.calls++
+	err, release := f.err, f.release
+	f.mu.Unlock()
+
+	if release != nil {
+		<-release
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model.Product{EAN: ean, Name: "Test"}, nil
+}
+
+func (f *fakeProvider) set(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *fakeProvider) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	ctx := context.Background()
+	p := &fakeProvider{err: errDown}
+	b := WithBreaker(p, 2, cooldown)
+
+	lookup := func(want error) {
+		t.Helper()
+		if _, err := b.Lookup(ctx, "4006381333931"); !errors.Is(err, want) {
+			t.Fatalf("Lookup error = %v, want %v", err, want)
+		}
+	}
+
+	// Closed: failures below the threshold and rate-limited lookups are
+	// passed through without opening the breaker.
+	lookup(errDown)
+	p.set(ErrRateLimited)
+	lookup(ErrRateLimited)
+	p.set(errDown)
+
+	// Open after the second consecutive failure: no more lookups are sent.
+	lookup(errDown)
+	calls := p.count()
+	lookup(ErrCircuitOpen)
+	if p.count() != calls {
+		t.Fatal("open breaker sent a lookup")
+	}
+
+	// Half-open after the cooldown: a failed probe opens it again at once.
+	time.Sleep(cooldown + 5*time.Millisecond)
+	lookup(errDown)
+	lookup(ErrCircuitOpen)
+
+	// A successful probe closes it.
+	time.Sleep(cooldown + 5*time.Millisecond)
+	p.set(nil)
+	lookup(nil)
+	lookup(nil)
+
+	// Closing resets the count of failures.
+	p.set(errDown)
+	lookup(errDown)
+	p.set(nil)
+	lookup(nil)
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	ctx := context.Background()
+	p := &fakeProvider{err: errDown}
+	b := WithBreaker(p, 1, cooldown)
+
+	if _, err := b.Lookup(ctx, "4006381333931"); !errors.Is(err, errDown) {
+		t.Fatalf("Lookup error = %v, want %v", err, errDown)
+	}
+	time.Sleep(cooldown + 5*time.Millisecond)
+
+	// While the probe is in flight, other lookups fail at once.
+	release := make(chan struct{})
+	p.mu.Lock()
+	p.err, p.release = nil, release
+	p.mu.Unlock()
+	done := make(chan error)
+	go func() {
+		_, err := b.Lookup(ctx, "4006381333931")
+		done <- err
+	}()
+	for p.count() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := b.Lookup(ctx, "4006381333931"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Lookup during probe error = %v, want %v", err, ErrCircuitOpen)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+
+	if _, err := b.Lookup(ctx, "4006381333931"); err != nil {
+		t.Errorf("Lookup after probe: %v", err)
+	}
+}
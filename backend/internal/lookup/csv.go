@@ -0,0 +1,90 @@
+package lookup
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"foodinventory/internal/gtin"
+	"foodinventory/internal/model"
+)
+
+// CSV looks products up in a local CSV file, for products that are in no
+// public database. The file is read once at startup.
+type CSV struct {
+	products map[string]csvProduct
+}
+
+type csvProduct struct {
+	name, category, imageURL string
+}
+
+// LoadCSV reads a CSV file whose header row names the columns ean and name,
+// and optionally category and image_url, in any order. Barcodes may be
+// given in any supported form; they are stored under their canonical key.
+func LoadCSV(path string) (*CSV, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading header: %w", path, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"ean", "name"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%s: missing column %q", path, required)
+		}
+	}
+
+	c := &CSV{products: map[string]csvProduct{}}
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		line, _ := r.FieldPos(0)
+		get := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		key, err := gtin.Normalize(get("ean"))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		name := get("name")
+		if name == "" {
+			return nil, fmt.Errorf("%s:%d: name must not be empty", path, line)
+		}
+		c.products[key] = csvProduct{name: name, category: get("category"), imageURL: get("image_url")}
+	}
+	return c, nil
+}
+
+// Lookup returns the product from the file.
+func (c *CSV) Lookup(_ context.Context, ean string) (*model.Product, error) {
+	p, ok := c.products[ean]
+	if !ok {
+		return nil, nil
+	}
+	return newProduct(ean, p.name, p.category, p.imageURL), nil
+}
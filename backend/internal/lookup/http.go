@@ -0,0 +1,121 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// retryBackoff is the delay before the first retry; every further retry
+// doubles it. The actual delay is drawn at random up to that value (full
+// jitter), so clients that failed together do not retry together.
+const retryBackoff = 100 * time.Millisecond
+
+// maxBodySize limits the size of a response body. Product responses are a
+// few kilobytes; a larger body is not an answer we can use.
+const maxBodySize = 4 << 20
+
+// StatusError is returned for HTTP responses other than 200 and 404.
+type StatusError struct {
+	URL  string
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GET %s: unexpected status %d %s", e.URL, e.Code, http.StatusText(e.Code))
+}
+
+// temporary reports whether the request may succeed when retried.
+func (e *StatusError) temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// HTTP sends the requests of the HTTP-based providers. It keeps its own pool
+// of connections, so lookups reuse established connections instead of
+// paying for a new TLS handshake, and retries transient failures.
+type HTTP struct {
+	client   *http.Client
+	attempts int
+}
+
+// NewHTTP returns an HTTP client that makes up to attempts attempts per
+// request.
+func NewHTTP(attempts int) *HTTP {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 4
+	transport.IdleConnTimeout = 5 * time.Minute
+	return &HTTP{client: &http.Client{Transport: transport}, attempts: max(attempts, 1)}
+}
+
+// get fetches url and returns the body of a 200 response, or nil for a 404
+// response. Other responses fail with a *StatusError. Network errors and
+// responses with status 429 or 5xx are retried with jittered exponential
+// backoff, as long as the retry fits in the deadline of ctx.
+func (h *HTTP) get(ctx context.Context, url string, header http.Header) ([]byte, error) {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		body, err := h.try(ctx, url, header)
+		if err == nil || attempt >= h.attempts || !retryable(ctx, err) {
+			return body, err
+		}
+
+		delay := rand.N(backoff)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		backoff *= 2
+	}
+}
+
+func (h *HTTP) try(ctx context.Context, url string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+		if err == nil && len(body) > maxBodySize {
+			return nil, fmt.Errorf("GET %s: response body exceeds %d bytes", url, maxBodySize)
+		}
+		return body, err
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, &StatusError{URL: url, Code: resp.StatusCode}
+	}
+}
+
+// retryable reports whether a failed request may be retried: temporary
+// HTTP errors and network errors, but not the expiry of ctx itself.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.temporary()
+	}
+	return true
+}
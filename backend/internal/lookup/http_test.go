@@ -0,0 +1,125 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// statusServer answers the n-th request with the n-th status, repeating the
+// last one, and records when the requests arrived.
+type statusServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	arrivals []time.Time
+}
+
+func newStatusServer(t *testing.T, statuses ...int) *statusServer {
+	s := &statusServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		n := len(s.arrivals)
+		s.arrivals = append(s.arrivals, time.Now())
+		s.mu.Unlock()
+
+		status := s.statuses[min(n, len(s.statuses)-1)]
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"status":1}`))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *statusServer) requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.arrivals)
+}
+
+func TestHTTPGet(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     string // body; "" for none
+		status   int    // code of the *StatusError; 0 for none
+		requests int
+	}{
+		{"ok", []int{200}, `{"status":1}`, 0, 1},
+		{"not found", []int{404}, "", 0, 1},
+		{"5xx retried", []int{500, 200}, `{"status":1}`, 0, 2},
+		{"429 retried", []int{429, 502, 200}, `{"status":1}`, 0, 3},
+		{"404 after retry", []int{503, 404}, "", 0, 2},
+		{"attempts exhausted", []int{503}, "", 503, 3},
+		{"4xx not retried", []int{400, 200}, "", 400, 1},
+		{"403 not retried", []int{403, 200}, "", 403, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newStatusServer(t, tt.statuses...)
+			body, err := NewHTTP(3).get(context.Background(), srv.URL, nil)
+
+			var status *StatusError
+			switch {
+			case tt.status == 0 && err != nil:
+				t.Errorf("get: %v", err)
+			case tt.status != 0 && (!errors.As(err, &status) || status.Code != tt.status):
+				t.Errorf("get error = %v, want status %d", err, tt.status)
+			}
+			if string(body) != tt.want {
+				t.Errorf("get body = %q, want %q", body, tt.want)
+			}
+			if got := srv.requests(); got != tt.requests {
+				t.Errorf("server got %d requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestHTTPGetBackoff(t *testing.T) {
+	srv := newStatusServer(t, 503, 503, 200)
+	if _, err := NewHTTP(3).get(context.Background(), srv.URL, nil); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	// Each delay is drawn from [0, backoff), and the backoff doubles.
+	const slack = 50 * time.Millisecond
+	backoff := retryBackoff
+	for i := 1; i < len(srv.arrivals); i++ {
+		if gap := srv.arrivals[i].Sub(srv.arrivals[i-1]); gap > backoff+slack {
+			t.Errorf("retry %d after %v, want at most %v", i, gap, backoff)
+		}
+		backoff *= 2
+	}
+}
+
+func TestHTTPGetCancelled(t *testing.T) {
+	srv := newStatusServer(t, 503)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewHTTP(3).get(ctx, srv.URL, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("get error = %v, want context.Canceled", err)
+	}
+	if got := srv.requests(); got != 0 {
+		t.Errorf("server got %d requests, want 0", got)
+	}
+}
+
+func TestHTTPGetBodyLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", maxBodySize+1)))
+	}))
+	defer srv.Close()
+
+	body, err := NewHTTP(1).get(context.Background(), srv.URL, nil)
+	if err == nil || body != nil {
+		t.Errorf("get = %d bytes, %v; want an error", len(body), err)
+	}
+}
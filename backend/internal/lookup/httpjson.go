@@ -0,0 +1,81 @@
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"foodinventory/internal/gtin"
+	"foodinventory/internal/model"
+)
+
+// HTTPJSON looks products up in any HTTP API that answers with JSON, such as
+// a self-hosted product database.
+type HTTPJSON struct {
+	// URL is the product endpoint; {ean} is replaced by the barcode.
+	URL string
+	// Header is sent with every request, e.g. an Authorization header.
+	Header http.Header
+	// NameField, CategoryField and ImageField are dot-separated paths to
+	// the product fields in the response, e.g. "product.title" or
+	// "categories.0". Only NameField is required.
+	NameField, CategoryField, ImageField string
+	// HTTP sends the requests.
+	HTTP *HTTP
+}
+
+// Lookup fetches the product. A 404 response or a response without a name
+// means the product is unknown.
+func (h *HTTPJSON) Lookup(ctx context.Context, ean string) (*model.Product, error) {
+	url := strings.ReplaceAll(h.URL, "{ean}", gtin.Compact(ean))
+	data, err := h.HTTP.get(ctx, url, h.Header)
+	if err != nil {
+		return nil, fmt.Errorf("http: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var body any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("http: %w", err)
+	}
+
+	name := field(body, h.NameField)
+	if name == "" {
+		return nil, nil
+	}
+	return newProduct(ean, name, field(body, h.CategoryField), field(body, h.ImageField)), nil
+}
+
+// field returns the string at the dot-separated path in v, or "" when the
+// path is empty, does not exist or does not lead to a string or number.
+// Numeric path elements index into arrays.
+func field(v any, path string) string {
+	if path == "" {
+		return ""
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return ""
+			}
+			v = node[i]
+		default:
+			return ""
+		}
+	}
+	switch value := v.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"foodinventory/internal/model"
+)
+
+// ErrRateLimited is returned by a rate-limited provider when the request was
+// not sent because the rate limit is used up.
+var ErrRateLimited = errors.New("product lookup rate limit exceeded")
+
+// Limiter is a token bucket. It holds up to burst tokens and refills
+// continuously at the configured rate; every request takes one token. It is
+// safe for concurrent use.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a full bucket that allows perMinute requests per minute
+// on average, and bursts of up to burst requests.
+func NewLimiter(perMinute, burst int) *Limiter {
+	return &Limiter{
+		rate:   float64(perMinute) / 60,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token and reports whether one was available.
+func (l *Limiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Limit returns a provider that takes a token from l before every lookup
+// with p and fails with ErrRateLimited when there is none. Providers sharing
+// one limiter share its rate.
+func Limit(p ProductLookup, l *Limiter) ProductLookup {
+	return &limited{provider: p, limiter: l}
+}
+
+type limited struct {
+	provider ProductLookup
+	limiter  *Limiter
+}
+
+func (r *limited) Lookup(ctx context.Context, ean string) (*model.Product, error) {
+	if !r.limiter.Allow() {
+		return nil, ErrRateLimited
+	}
+	return r.provider.Lookup(ctx, ean)
+}
@@ -0,0 +1,76 @@
+// Package lookup resolves barcodes to product metadata from external
+// sources such as Open Food Facts, a JSON HTTP API or a local CSV file.
+// Providers are combined into an ordered fallback Chain.
+package lookup
+
+import (
+	"context"
+	"time"
+
+	"foodinventory/internal/model"
+)
+
+// userAgent identifies the inventory to the public product databases, as
+// their terms of use ask.
+const userAgent = "FoodInventory/1.0 (home warehouse tool)"
+
+// ProductLookup resolves a canonical product key (see gtin.Normalize) to
+// product metadata. Lookup returns nil, nil when the source does not know
+// the product.
+type ProductLookup interface {
+	Lookup(ctx context.Context, ean string) (*model.Product, error)
+}
+
+// Chain asks its providers in order and returns the first product found.
+// Each provider gets its own timeout, so a slow source does not use up the
+// time of the ones after it.
+type Chain struct {
+	providers []ProductLookup
+	timeout   time.Duration
+}
+
+// NewChain returns a chain of providers, each limited to timeout per lookup.
+func NewChain(timeout time.Duration, providers ...ProductLookup) *Chain {
+	return &Chain{providers: providers, timeout: timeout}
+}
+
+// Lookup returns the product from the first provider that knows it. When
+// none does, the first provider error is returned, so a lookup that failed
+// or timed out is retried later instead of taking the product as unknown.
+func (c *Chain) Lookup(ctx context.Context, ean string) (*model.Product, error) {
+	var firstErr error
+	for _, provider := range c.providers {
+		lookupCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		p, err := provider.Lookup(lookupCtx, ean)
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if p != nil {
+			return p, nil
+		}
+	}
+	return nil, firstErr
+}
+
+// newProduct returns a resolved product with the default measure. Empty
+// category and image URL are left unset.
+func newProduct(ean, name, category, imageURL string) *model.Product {
+	p := &model.Product{
+		EAN: ean, Name: name, Unit: model.UnitPieces,
+		Aliases: []string{}, ContainedIn: []string{},
+	}
+	if category != "" {
+		p.Category = &category
+	}
+	if imageURL != "" {
+		p.ImageURL = &imageURL
+	}
+	return p
+}
@@ -0,0 +1,83 @@
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"foodinventory/internal/gtin"
+	"foodinventory/internal/model"
+)
+
+// Public servers of the Open Food Facts family of databases.
+const (
+	OpenFoodFactsURL     = "https://world.openfoodfacts.org"
+	OpenBeautyFactsURL   = "https://world.openbeautyfacts.org"
+	OpenProductsFactsURL = "https://world.openproductsfacts.org"
+)
+
+// OpenFacts looks products up in one of the Open Food Facts family of
+// databases, which share the same API: Open Food Facts for food, Open Beauty
+// Facts for cosmetics and Open Products Facts for other goods such as
+// household products.
+type OpenFacts struct {
+	name    string
+	baseURL string
+	http    *HTTP
+}
+
+// OpenFoodFacts returns a provider for Open Food Facts served at baseURL,
+// e.g. OpenFoodFactsURL or a self-hosted mirror.
+func OpenFoodFacts(baseURL string, h *HTTP) *OpenFacts {
+	return newOpenFacts("openfoodfacts", baseURL, h)
+}
+
+// OpenBeautyFacts returns a provider for Open Beauty Facts served at baseURL.
+func OpenBeautyFacts(baseURL string, h *HTTP) *OpenFacts {
+	return newOpenFacts("openbeautyfacts", baseURL, h)
+}
+
+// OpenProductsFacts returns a provider for Open Products Facts served at
+// baseURL.
+func OpenProductsFacts(baseURL string, h *HTTP) *OpenFacts {
+	return newOpenFacts("openproductsfacts", baseURL, h)
+}
+
+func newOpenFacts(name, baseURL string, h *HTTP) *OpenFacts {
+	return &OpenFacts{name: name, baseURL: strings.TrimRight(baseURL, "/"), http: h}
+}
+
+// openFactsResponse maps the subset of the API response we need.
+type openFactsResponse struct {
+	Status  int `json:"status"`
+	Product struct {
+		ProductName        string   `json:"product_name"`
+		CategoriesTags     []string `json:"categories_tags"`
+		ImageFrontSmallURL string   `json:"image_front_small_url"`
+	} `json:"product"`
+}
+
+// Lookup fetches the product. The first category tag becomes the category.
+func (o *OpenFacts) Lookup(ctx context.Context, ean string) (*model.Product, error) {
+	data, err := o.http.get(ctx, o.baseURL+"/api/v2/product/"+gtin.Compact(ean), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", o.name, err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var body openFactsResponse
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("%s: %w", o.name, err)
+	}
+	if body.Status == 0 {
+		return nil, nil // product not found
+	}
+
+	var category string
+	if len(body.Product.CategoriesTags) > 0 {
+		category = body.Product.CategoriesTags[0]
+	}
+	return newProduct(ean, body.Product.ProductName, category, body.Product.ImageFrontSmallURL), nil
+}
@@ -1,27 +1,281 @@
 package model
 
-// Product holds EAN-resolved metadata cached from Open Food Facts.
+import (
+	"encoding/json"
+	"time"
+)
+
+// Product holds EAN-resolved metadata cached from the lookup providers, such
+// as Open Food Facts.
+// MinQuantity is set for watched ("always keep in stock") products.
+// TargetQuantity is the stock level the shopping list tops the product up to.
+// Amounts of the product are measured in Unit; PackageSize is the amount in
+// one package (nil means one piece) and LowStockAmount an optional low-stock
+// threshold in Unit. DaysAfterOpening is how long the product stays good once
+// opened. Aliases are further barcodes that refer to the product, and
+// GroupID is the product group it belongs to. A case or multipack contains
+// ContainsQuantity units of the product ContainsEAN; ContainedIn lists the
+// packages that contain the product. LookupStatus and LookedUpAt describe
+// the last lookup with the providers; both are nil when there was none.
 type Product struct {
-	EAN      string  `json:"ean"`
-	Name     string  `json:"name"`
-	Category *string `json:"category"`
-	ImageURL *string `json:"image_url"`
-	Resolved bool    `json:"resolved"`
+	EAN              string   `json:"ean"`
+	Name             string   `json:"name"`
+	Category         *string  `json:"category"`
+	ImageURL         *string  `json:"image_url"`
+	Resolved         bool     `json:"resolved"`
+	MinQuantity      *int     `json:"min_quantity"`
+	TargetQuantity   *int     `json:"target_quantity"`
+	Unit             Unit     `json:"unit"`
+	PackageSize      *float64 `json:"package_size"`
+	LowStockAmount   *float64 `json:"low_stock_amount"`
+	DaysAfterOpening *int     `json:"days_after_opening"`
+	Aliases          []string `json:"aliases"`
+	GroupID          *int     `json:"group_id"`
+	ContainsEAN      *string  `json:"contains_ean"`
+	ContainsQuantity *int     `json:"contains_quantity"`
+	ContainedIn      []string `json:"contained_in"`
+
+	LookupStatus *LookupStatus `json:"lookup_status"`
+	LookedUpAt   *time.Time    `json:"looked_up_at"`
+}
+
+// LookupStatus is the outcome of a product lookup with the providers.
+type LookupStatus string
+
+const (
+	LookupFound    LookupStatus = "found"
+	LookupNotFound LookupStatus = "not_found" // no provider knows the product
+	LookupFailed   LookupStatus = "failed"    // a provider failed or timed out
+	// The lookup was not sent because of the provider rate limit.
+	LookupRateLimited LookupStatus = "rate_limited"
+)
+
+// Unit is a unit of measure for product amounts. Amounts are stored in the
+// base units pcs, g and ml; kg and l are accepted on input and converted.
+type Unit string
+
+const (
+	UnitPieces      Unit = "pcs"
+	UnitGrams       Unit = "g"
+	UnitKilograms   Unit = "kg"
+	UnitMillilitres Unit = "ml"
+	UnitLitres      Unit = "l"
+)
+
+// Base returns the base unit u is stored in and the factor converting an
+// amount in u to it. ok is false for unknown units.
+func (u Unit) Base() (base Unit, factor float64, ok bool) {
+	switch u {
+	case UnitPieces, UnitGrams, UnitMillilitres:
+		return u, 1, true
+	case UnitKilograms:
+		return UnitGrams, 1000, true
+	case UnitLitres:
+		return UnitMillilitres, 1000, true
+	}
+	return "", 0, false
+}
+
+// Valid reports whether u is a known unit.
+func (u Unit) Valid() bool {
+	_, _, ok := u.Base()
+	return ok
+}
+
+// Convert converts amount from u to unit to. ok is false when the units are
+// not compatible, e.g. grams and millilitres.
+func (u Unit) Convert(amount float64, to Unit) (float64, bool) {
+	from, f1, ok1 := u.Base()
+	base, f2, ok2 := to.Base()
+	if !ok1 || !ok2 || from != base {
+		return 0, false
+	}
+	return amount * f1 / f2, true
+}
+
+// MeasureRequest is the body for PUT /products/{ean}/unit. PackageSize and
+// LowStockAmount are given in Unit; nil PackageSize means one piece.
+type MeasureRequest struct {
+	Unit           Unit     `json:"unit"`
+	PackageSize    *float64 `json:"package_size"`
+	LowStockAmount *float64 `json:"low_stock_amount"`
+}
+
+// WatchedProduct is a product on the watch list with its total stock.
+type WatchedProduct struct {
+	Product  Product `json:"product"`
+	Quantity int     `json:"quantity"`
 }
 
-// InventoryEntry is one product line in the current stock.
+// WatchRequest is the body for PUT /products/{ean}/watch.
+type WatchRequest struct {
+	MinQuantity int `json:"min_quantity"`
+}
+
+// ContentsRequest is the body for PUT /products/{ean}/contents.
+type ContentsRequest struct {
+	EAN      string `json:"ean"`
+	Quantity int    `json:"quantity"`
+}
+
+// AliasRequest is the body for POST /products/{ean}/aliases.
+type AliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+// MergeRequest is the body for POST /products/{ean}/merge. The product From
+// is merged into the product in the path and becomes one of its aliases.
+type MergeRequest struct {
+	From string `json:"from"`
+}
+
+// Location is a storage place such as the pantry, fridge or freezer.
+type Location struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ProductGroup is a set of interchangeable products, such as several brands
+// of milk, whose stock is checked against MinQuantity as a whole. Quantity
+// is the summed quantity of all members.
+type ProductGroup struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	MinQuantity int       `json:"min_quantity"`
+	Quantity    int       `json:"quantity"`
+	Members     []Product `json:"members"`
+}
+
+// InventoryEntry is one lot in the current stock: the units of a product
+// that share an expiry date, batch number and storage location. Quantity
+// counts packages, including a partly used one; Amount is what is left in the
+// product's unit. OpenedAt is set on lots of opened units; OpenedUseBy is then
+// the date they should be used by, when the product has a DaysAfterOpening
+// value. ConsumptionRate (units per day) and DepletionDate (when the product's
+// total stock runs out) are forecast per product from recent removals; both
+// are nil without any.
 type InventoryEntry struct {
-	ID                int     `json:"id"`
-	Product           Product `json:"product"`
-	Quantity          int     `json:"quantity"`
-	ExpiryDate        *string `json:"expiry_date"`
-	LowStockThreshold int     `json:"low_stock_threshold"`
+	ID                int       `json:"id"`
+	Product           Product   `json:"product"`
+	Quantity          int       `json:"quantity"`
+	Amount            float64   `json:"amount"`
+	ExpiryDate        *string   `json:"expiry_date"`
+	Batch             *string   `json:"batch"`
+	LowStockThreshold int       `json:"low_stock_threshold"`
+	OpenedAt          *string   `json:"opened_at"`
+	OpenedUseBy       *string   `json:"opened_use_by"`
+	Location          *Location `json:"location"`
+	ConsumptionRate   *float64  `json:"consumption_rate"`
+	DepletionDate     *string   `json:"depletion_date"`
 }
 
 // AddProductRequest is the body for POST /inventory.
+// Quantity defaults to 1 when omitted. Instead of EAN, Code may carry the raw
+// scan: a plain barcode or a GS1 element string, from which the EAN, and the
+// ExpiryDate and Batch unless given, are taken. Format is the symbology the
+// barcode was scanned from, if the scanner reports it (see gtin.Symbology).
 type AddProductRequest struct {
 	EAN        string  `json:"ean"`
+	Code       *string `json:"code"`
+	Format     *string `json:"format"`
 	ExpiryDate *string `json:"expiry_date"`
+	Batch      *string `json:"batch"`
+	LocationID *int    `json:"location_id"`
+	Quantity   *int    `json:"quantity"`
+}
+
+// RemoveProductRequest is the optional body for DELETE /inventory/{ean}.
+// Either Quantity (packages, default 1) or Amount is given; Amount is in Unit,
+// which defaults to the product's unit. When LocationID is nil units are taken
+// from any location. Reason and the free-text Note are recorded in the stock
+// movement ledger.
+type RemoveProductRequest struct {
+	LocationID *int           `json:"location_id"`
+	Quantity   *int           `json:"quantity"`
+	Amount     *float64       `json:"amount"`
+	Unit       *Unit          `json:"unit"`
+	Reason     *RemovalReason `json:"reason"`
+	Note       *string        `json:"note"`
+}
+
+// RemovalReason explains why units left the stock.
+type RemovalReason string
+
+const (
+	ReasonConsumed RemovalReason = "consumed"
+	ReasonExpired  RemovalReason = "expired"
+	ReasonSpoiled  RemovalReason = "spoiled"
+	ReasonDonated  RemovalReason = "donated"
+	ReasonOther    RemovalReason = "other"
+)
+
+// Valid reports whether r is one of the known removal reasons.
+func (r RemovalReason) Valid() bool {
+	switch r {
+	case ReasonConsumed, ReasonExpired, ReasonSpoiled, ReasonDonated, ReasonOther:
+		return true
+	}
+	return false
+}
+
+// SetQuantityRequest is the body for PUT /inventory/{ean}/quantity.
+// Quantity is required. When LocationID is nil the count covers all locations.
+type SetQuantityRequest struct {
+	Quantity   *int `json:"quantity"`
+	LocationID *int `json:"location_id"`
+}
+
+// UpdateInventoryEntryRequest is the body for PATCH /inventory/{id}.
+// Absent fields are left unchanged; ExpiryDate may be null to clear it.
+// Amount records what is left of the lot, in Unit (default: the product's
+// unit); the package count follows from it.
+type UpdateInventoryEntryRequest struct {
+	ExpiryDate        OptionalString `json:"expiry_date"`
+	LowStockThreshold *int           `json:"low_stock_threshold"`
+	Amount            *float64       `json:"amount"`
+	Unit              *Unit          `json:"unit"`
+}
+
+// OptionalString distinguishes an absent JSON field (Set == false) from an
+// explicit null (Set == true, Value == nil).
+type OptionalString struct {
+	Set   bool
+	Value *string
+}
+
+func (o *OptionalString) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+// MoveRequest is the body for POST /inventory/{ean}/move.
+// A nil location ID refers to stock without an assigned location.
+type MoveRequest struct {
+	FromLocationID *int `json:"from_location_id"`
+	ToLocationID   *int `json:"to_location_id"`
+	Quantity       int  `json:"quantity"`
+}
+
+// LocationRequest is the body for POST /locations and PATCH /locations/{id}.
+type LocationRequest struct {
+	Name string `json:"name"`
+}
+
+// GroupRequest is the body for POST /groups and PATCH /groups/{id}.
+type GroupRequest struct {
+	Name        string `json:"name"`
+	MinQuantity int    `json:"min_quantity"`
+}
+
+// CreateProductRequest is the body for POST /products, which creates a
+// product without a barcode. Unit defaults to pcs; PackageSize is given in
+// Unit like in MeasureRequest.
+type CreateProductRequest struct {
+	Name             string   `json:"name"`
+	Category         *string  `json:"category"`
+	Unit             *Unit    `json:"unit"`
+	PackageSize      *float64 `json:"package_size"`
+	DaysAfterOpening *int     `json:"days_after_opening"`
 }
 
 // UpdateProductRequest is the body for PATCH /products/{ean}.
@@ -30,29 +284,170 @@ type UpdateProductRequest struct {
 	Category *string `json:"category"`
 }
 
+// EventSource identifies the operation that produced a stock event.
+type EventSource string
+
+const (
+	EventAdd           EventSource = "add"
+	EventRemove        EventSource = "remove"
+	EventMove          EventSource = "move"
+	EventSet           EventSource = "set"
+	EventProductUpdate EventSource = "product_update"
+	EventUndo          EventSource = "undo"
+	EventOpen          EventSource = "open"
+	EventMerge         EventSource = "merge"
+)
+
+// InventoryEvent is one entry of the append-only stock movement ledger.
+// QuantityAfter is the product's total stock across all lots after the change.
+// Events written by one API call share an OperationID, which is the handle
+// used to undo that call.
+type InventoryEvent struct {
+	ID                 int64          `json:"id"`
+	OperationID        int64          `json:"operation_id"`
+	OccurredAt         time.Time      `json:"occurred_at"`
+	EAN                string         `json:"ean"`
+	ProductName        string         `json:"product_name"`
+	InventoryID        *int           `json:"inventory_id"`
+	LocationID         *int           `json:"location_id"`
+	Delta              int            `json:"delta"`
+	AmountDelta        float64        `json:"amount_delta"`
+	QuantityAfter      int            `json:"quantity_after"`
+	Source             EventSource    `json:"source"`
+	Reason             *RemovalReason `json:"reason"`
+	Note               *string        `json:"note"`
+	RevertsOperationID *int64         `json:"reverts_operation_id"`
+}
+
+// UndoResult is the response of the undo endpoints.
+type UndoResult struct {
+	OperationID int64            `json:"operation_id"`
+	EAN         string           `json:"ean"`
+	Entries     []InventoryEntry `json:"entries"`
+}
+
+// HistoryFilter restricts the events returned by the history endpoints.
+// Nil fields are not filtered on; From is inclusive and To exclusive.
+type HistoryFilter struct {
+	EAN   *string
+	From  *time.Time
+	To    *time.Time
+	Limit int
+}
+
+// WasteCount counts discarded units, in total and per waste reason.
+type WasteCount struct {
+	Units   float64 `json:"units"`
+	Expired float64 `json:"expired"`
+	Spoiled float64 `json:"spoiled"`
+}
+
+// ProductWaste is the waste of one product.
+type ProductWaste struct {
+	EAN         string  `json:"ean"`
+	ProductName string  `json:"product_name"`
+	Category    *string `json:"category"`
+	WasteCount
+}
+
+// CategoryWaste is the waste of one product category; Category is nil for
+// products without a category.
+type CategoryWaste struct {
+	Category *string `json:"category"`
+	WasteCount
+}
+
+// MonthWaste is the waste of one calendar month (YYYY-MM).
+type MonthWaste struct {
+	Month string `json:"month"`
+	WasteCount
+}
+
+// WasteReport is the response of GET /reports/waste: units removed as
+// expired or spoiled within the period [From, To), aggregated three ways.
+type WasteReport struct {
+	From       *time.Time      `json:"from"`
+	To         *time.Time      `json:"to"`
+	Total      WasteCount      `json:"total"`
+	ByProduct  []ProductWaste  `json:"by_product"`
+	ByCategory []CategoryWaste `json:"by_category"`
+	ByMonth    []MonthWaste    `json:"by_month"`
+}
+
+// ShoppingListItem is one entry of the shopping list. Automatic items are
+// maintained from low-stock and out-of-stock conditions.
+type ShoppingListItem struct {
+	ID        int       `json:"id"`
+	EAN       *string   `json:"ean"`
+	Name      string    `json:"name"`
+	Quantity  int       `json:"quantity"`
+	Checked   bool      `json:"checked"`
+	Automatic bool      `json:"automatic"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddShoppingListItemRequest is the body for POST /shopping-list.
+// At least one of EAN and Name is required; Quantity defaults to 1.
+type AddShoppingListItemRequest struct {
+	EAN      *string `json:"ean"`
+	Name     *string `json:"name"`
+	Quantity *int    `json:"quantity"`
+}
+
+// UpdateShoppingListItemRequest is the body for PATCH /shopping-list/{id}.
+// Absent fields are left unchanged.
+type UpdateShoppingListItemRequest struct {
+	Checked  *bool `json:"checked"`
+	Quantity *int  `json:"quantity"`
+}
+
+// DaysAfterOpeningRequest is the body for PUT
+// /products/{ean}/days-after-opening. A nil value clears it.
+type DaysAfterOpeningRequest struct {
+	DaysAfterOpening *int `json:"days_after_opening"`
+}
+
+// TargetQuantityRequest is the body for PUT /products/{ean}/target-quantity.
+// A nil TargetQuantity restores the default.
+type TargetQuantityRequest struct {
+	TargetQuantity *int `json:"target_quantity"`
+}
+
 // AlertType classifies an alert.
 type AlertType string
 
 const (
-	AlertLowStock   AlertType = "low_stock"
-	AlertExpirySoon AlertType = "expiry_soon"
+	AlertLowStock       AlertType = "low_stock"
+	AlertOutOfStock     AlertType = "out_of_stock"
+	AlertExpirySoon     AlertType = "expiry_soon"
+	AlertDepletionSoon  AlertType = "depletion_soon"
+	AlertOpenedExpiring AlertType = "opened_expiring"
 )
 
 // Alert represents a single active warning surfaced to the user.
+// InventoryID identifies the affected lot for lot-level alerts (expiry) and
+// is omitted for product-level alerts (low stock, out of stock). GroupID is
+// set on stock alerts of product groups; ProductName then holds the group
+// name and EAN the member to restock.
 type Alert struct {
 	Type        AlertType `json:"type"`
 	EAN         string    `json:"ean"`
+	InventoryID *int      `json:"inventory_id,omitempty"`
+	GroupID     *int      `json:"group_id,omitempty"`
 	ProductName string    `json:"product_name"`
 	Detail      string    `json:"detail"`
 }
 
 // Settings holds global application configuration stored in the database.
 type Settings struct {
-	ExpiryWarningDays int `json:"expiry_warning_days"`
+	ExpiryWarningDays    int `json:"expiry_warning_days"`
+	DepletionHorizonDays int `json:"depletion_horizon_days"`
 }
 
-// APIError is the standard error response body.
+// APIError is the standard error response body. Details optionally carries
+// structured information about the error.
 type APIError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
 }
@@ -0,0 +1,222 @@
+// Package offdump reads the data exports of Open Food Facts: the CSV export
+// (en.openfoodfacts.org.products.csv, tab-separated) and the JSONL export
+// (openfoodfacts-products.jsonl), each optionally gzip-compressed. Files are
+// streamed one product at a time, so the multi-gigabyte exports are never
+// loaded into memory.
+package offdump
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Product is a product record of an export. Fields are as found in the
+// file; the code is not validated.
+type Product struct {
+	Code      string
+	Name      string
+	Category  string // first category tag, e.g. "en:beverages"
+	ImageURL  string
+	Countries []string // country tags, e.g. "en:germany"
+}
+
+// In reports whether the product is sold in any of the given countries.
+func (p *Product) In(countries []string) bool {
+	for _, c := range p.Countries {
+		if slices.Contains(countries, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// CountryTag returns the Open Food Facts tag of a country given by its tag
+// or English name: "Germany", "united kingdom" and "en:germany" become
+// "en:germany" and "en:united-kingdom".
+func CountryTag(country string) string {
+	tag := strings.ToLower(strings.Join(strings.Fields(country), "-"))
+	if tag != "" && !strings.Contains(tag, ":") {
+		tag = "en:" + tag
+	}
+	return tag
+}
+
+// RecordError is returned by Reader.Read for a record that cannot be
+// decoded, such as a truncated or garbled line. Reading can continue with
+// the next record.
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads the products of an export file.
+type Reader struct {
+	file *os.File
+	gz   *gzip.Reader
+	read func() (*Product, error)
+}
+
+// Open opens an export file. The format is taken from the file name, which
+// must contain ".csv" or ".jsonl"; gzip compression is detected from the
+// content.
+func Open(path string) (*Reader, error) {
+	name := strings.ToLower(path)
+	jsonl := strings.Contains(name, ".jsonl")
+	if !jsonl && !strings.Contains(name, ".csv") {
+		return nil, fmt.Errorf("%s: unknown export format, expected a .csv or .jsonl file", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{file: f}
+	buf := bufio.NewReaderSize(f, 1<<20)
+	src := buf
+	if magic, _ := buf.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		if r.gz, err = gzip.NewReader(buf); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		src = bufio.NewReaderSize(r.gz, 1<<20)
+	}
+
+	if jsonl {
+		r.read = jsonlReader(src)
+	} else if r.read, err = csvReader(src); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Read returns the next product, or io.EOF after the last one. A record that
+// cannot be decoded yields a *RecordError, after which Read may be called
+// again.
+func (r *Reader) Read() (*Product, error) {
+	return r.read()
+}
+
+// Close closes the file.
+func (r *Reader) Close() error {
+	if r.gz != nil {
+		r.gz.Close()
+	}
+	return r.file.Close()
+}
+
+// jsonlRecord maps the subset of a JSONL record we need.
+type jsonlRecord struct {
+	Code               string   `json:"code"`
+	ProductName        string   `json:"product_name"`
+	CategoriesTags     []string `json:"categories_tags"`
+	CountriesTags      []string `json:"countries_tags"`
+	ImageFrontSmallURL string   `json:"image_front_small_url"`
+	ImageSmallURL      string   `json:"image_small_url"`
+}
+
+// jsonlReader decodes one product object per line. Blank lines are skipped.
+func jsonlReader(src *bufio.Reader) func() (*Product, error) {
+	n := 0
+	return func() (*Product, error) {
+		var line string
+		for line == "" {
+			var err error
+			if line, err = readLine(src); err != nil {
+				return nil, err
+			}
+			n++
+			line = strings.TrimSpace(line)
+		}
+		var rec jsonlRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, &RecordError{Line: n, Err: err}
+		}
+		p := &Product{
+			Code:      rec.Code,
+			Name:      rec.ProductName,
+			ImageURL:  cmp.Or(rec.ImageFrontSmallURL, rec.ImageSmallURL),
+			Countries: rec.CountriesTags,
+		}
+		if len(rec.CategoriesTags) > 0 {
+			p.Category = rec.CategoriesTags[0]
+		}
+		return p, nil
+	}
+}
+
+// csvReader reads the tab-separated CSV export. The export does not quote
+// or escape its fields, so lines are split on tabs rather than parsed with
+// encoding/csv, which would trip over stray quotes.
+func csvReader(src *bufio.Reader) (func() (*Product, error), error) {
+	line, err := readLine(src)
+	if err == io.EOF {
+		return nil, errors.New("empty file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range strings.Split(line, "\t") {
+		columns[name] = i
+	}
+	for _, required := range []string{"code", "product_name", "countries_tags"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	return func() (*Product, error) {
+		line, err := readLine(src)
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Split(line, "\t")
+		get := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(fields) {
+				return ""
+			}
+			return strings.TrimSpace(fields[i])
+		}
+		category, _, _ := strings.Cut(get("categories_tags"), ",")
+		var countries []string
+		if tags := get("countries_tags"); tags != "" {
+			countries = strings.Split(tags, ",")
+		}
+		return &Product{
+			Code:      get("code"),
+			Name:      get("product_name"),
+			Category:  category,
+			ImageURL:  cmp.Or(get("image_front_small_url"), get("image_small_url")),
+			Countries: countries,
+		}, nil
+	}, nil
+}
+
+// readLine returns the next line without its line ending, or io.EOF at the
+// end of src. The last line need not end with a newline.
+func readLine(src *bufio.Reader) (string, error) {
+	line, err := src.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
@@ -0,0 +1,147 @@
+package offdump
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// readAll reads every product of the export at path and counts the records
+// that could not be decoded.
+func readAll(t *testing.T, path string) ([]string, int) {
+	t.Helper()
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var codes []string
+	bad := 0
+	for {
+		p, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return codes, bad
+		}
+		var recErr *RecordError
+		if errors.As(err, &recErr) {
+			bad++
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes = append(codes, p.Code)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const jsonlExport = `{"code":"4006381333931","product_name":"Spaghetti","categories_tags":["en:pasta"],"countries_tags":["en:germany"]}
+{"code":"0036000291452","product_name":"Cor
+{"code":"96385074","product_name":"Tea","countries_tags":"en:france"}
+
+{"code":"5000159407236","product_name":"Chocolate","countries_tags":["en:united-kingdom"],"image_small_url":"https://example.com/c.jpg"}
+`
+
+func TestJSONLSkipsBadRecords(t *testing.T) {
+	codes, bad := readAll(t, writeFile(t, "products.jsonl", jsonlExport))
+	if want := []string{"4006381333931", "5000159407236"}; !slices.Equal(codes, want) {
+		t.Errorf("codes = %v, want %v", codes, want)
+	}
+	// A truncated line and one with a field of the wrong type.
+	if bad != 2 {
+		t.Errorf("bad records = %d, want 2", bad)
+	}
+}
+
+func TestJSONLRecordErrorLine(t *testing.T) {
+	r, err := Open(writeFile(t, "products.jsonl", jsonlExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Read()
+	_, err = r.Read()
+	var recErr *RecordError
+	if !errors.As(err, &recErr) || recErr.Line != 2 {
+		t.Errorf("second Read error = %v, want a *RecordError for line 2", err)
+	}
+	p, err := r.Read()
+	if err == nil {
+		t.Errorf("third Read = %+v, want a *RecordError for line 3", p)
+	}
+	if p, err = r.Read(); err != nil || p.Code != "5000159407236" || p.ImageURL != "https://example.com/c.jpg" {
+		t.Errorf("Read after bad records = %+v, %v", p, err)
+	}
+}
+
+func TestGzipJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.jsonl.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(jsonlExport))
+	gz.Close()
+	f.Close()
+
+	codes, bad := readAll(t, path)
+	if len(codes) != 2 || bad != 2 {
+		t.Errorf("read %v and %d bad records, want 2 products and 2 bad records", codes, bad)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	path := writeFile(t, "products.csv",
+		"code\tproduct_name\tcategories_tags\tcountries_tags\n"+
+			"4006381333931\tSpaghetti\ten:pasta,en:dry\ten:germany,en:austria\r\n"+
+			"96385074\tTea \"Earl Grey\n"+
+			"5000159407236\tChocolate\t\ten:united-kingdom")
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	p, err := r.Read()
+	if err != nil || p.Code != "4006381333931" || p.Category != "en:pasta" ||
+		!slices.Equal(p.Countries, []string{"en:germany", "en:austria"}) {
+		t.Errorf("first Read = %+v, %v", p, err)
+	}
+	if p, err = r.Read(); err != nil || p.Name != `Tea "Earl Grey` || p.Countries != nil {
+		t.Errorf("short line = %+v, %v", p, err)
+	}
+	if p, err = r.Read(); err != nil || p.Code != "5000159407236" || p.Category != "" {
+		t.Errorf("last line = %+v, %v", p, err)
+	}
+	if _, err = r.Read(); !errors.Is(err, io.EOF) {
+		t.Errorf("Read at the end: error = %v, want io.EOF", err)
+	}
+}
+
+func TestCountryTag(t *testing.T) {
+	for in, want := range map[string]string{
+		"Germany":           "en:germany",
+		" united  kingdom ": "en:united-kingdom",
+		"en:france":         "en:france",
+		"":                  "",
+	} {
+		if got := CountryTag(in); got != want {
+			t.Errorf("CountryTag(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"fmt"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -19,22 +21,274 @@ func NewAlertService(db *pgxpool.Pool) *AlertService {
 	return &AlertService{db: db}
 }
 
-// List returns all active low-stock and expiry-soon alerts.
+// List returns all active low-stock, out-of-stock, depletion-soon,
+// expiry-soon and opened-expiring alerts.
+// Stock levels are evaluated per product on the summed quantity of all its
+// lots, and per product group on the summed quantity of all its members;
+// expiry is evaluated per lot, so each expiry alert points at the lot
+// that is about to expire.
 // Alerts are computed on demand; no background jobs are required.
 func (s *AlertService) List(ctx context.Context) ([]model.Alert, error) {
-	var expiryWarningDays int
+	var expiryWarningDays, depletionHorizonDays int
 	err := s.db.QueryRow(ctx,
-		`SELECT expiry_warning_days FROM settings WHERE id = 1`,
-	).Scan(&expiryWarningDays)
+		`SELECT expiry_warning_days, depletion_horizon_days FROM settings WHERE id = 1`,
+	).Scan(&expiryWarningDays, &depletionHorizonDays)
 	if err != nil {
 		return nil, err
 	}
 
+	alerts := []model.Alert{}
+
+	levels, err := s.stockLevels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, stockAlerts(levels)...)
+
+	groups, err := s.groupLevels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, stockAlerts(groups)...)
+
+	depletion, err := s.depletionAlerts(ctx, levels, depletionHorizonDays)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, depletion...)
+
+	expirySoon, err := s.expiryAlerts(ctx, expiryWarningDays)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, expirySoon...)
+
+	openedExpiring, err := s.openedExpiringAlerts(ctx, expiryWarningDays)
+	if err != nil {
+		return nil, err
+	}
+	alerts = append(alerts, openedExpiring...)
+
+	return alerts, nil
+}
+
+// stockLevel is the aggregated stock of a product. lowStockAmount, when
+// set, replaces threshold with a threshold on the amount in unit.
+// For product groups groupID is set, name is the group name and ean the
+// member to restock.
+type stockLevel struct {
+	groupID        *int
+	ean, name      string
+	quantity       int
+	threshold      int
+	target         int
+	amount         float64
+	unit           model.Unit
+	size           float64
+	lowStockAmount *float64
+}
+
+// low reports whether the stock is at or below the threshold.
+func (l stockLevel) low() bool {
+	if l.lowStockAmount != nil {
+		return l.amount <= *l.lowStockAmount
+	}
+	return l.quantity <= l.threshold
+}
+
+// packagesAboveThreshold returns how many packages can be used before the
+// stock is low.
+func (l stockLevel) packagesAboveThreshold() float64 {
+	if l.lowStockAmount != nil {
+		return (l.amount - *l.lowStockAmount) / l.size
+	}
+	return float64(l.quantity - l.threshold)
+}
+
+// stockAlerts returns one alert per product or group whose total quantity is
+// at or below its threshold. Products and groups without any stock left raise
+// out_of_stock instead of low_stock.
+func stockAlerts(levels []stockLevel) []model.Alert {
+	alerts := []model.Alert{}
+	for _, l := range levels {
+		if !l.low() {
+			continue
+		}
+		if l.quantity == 0 {
+			alerts = append(alerts, model.Alert{
+				Type:        model.AlertOutOfStock,
+				EAN:         l.ean,
+				GroupID:     l.groupID,
+				ProductName: l.name,
+				Detail:      fmt.Sprintf("Out of stock (minimum: %d)", l.threshold),
+			})
+			continue
+		}
+		detail := fmt.Sprintf("Only %d item(s) left (threshold: %d)", l.quantity, l.threshold)
+		if l.lowStockAmount != nil {
+			detail = fmt.Sprintf("Only %s left (threshold: %s)",
+				formatAmount(l.amount, l.unit), formatAmount(*l.lowStockAmount, l.unit))
+		}
+		alerts = append(alerts, model.Alert{
+			Type:        model.AlertLowStock,
+			EAN:         l.ean,
+			GroupID:     l.groupID,
+			ProductName: l.name,
+			Detail:      detail,
+		})
+	}
+	return alerts
+}
+
+// depletionAlerts returns one depletion_soon alert per product that is not
+// low on stock yet but, at its recent consumption rate, is projected to
+// reach its threshold within horizonDays.
+func (s *AlertService) depletionAlerts(
+	ctx context.Context, levels []stockLevel, horizonDays int,
+) ([]model.Alert, error) {
+	rates, err := consumptionRates(ctx, s.db, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []model.Alert{}
+	for _, l := range levels {
+		c, ok := rates[l.ean]
+		if !ok || l.low() {
+			continue
+		}
+		days := c.daysUntil(l.packagesAboveThreshold())
+		if days > float64(horizonDays) {
+			continue
+		}
+		alerts = append(alerts, model.Alert{
+			Type:        model.AlertDepletionSoon,
+			EAN:         l.ean,
+			ProductName: l.name,
+			Detail: fmt.Sprintf(
+				"%d item(s) left, expected to reach the threshold (%d) in %d day(s) at %.2f item(s)/day",
+				l.quantity, l.threshold, int(math.Ceil(days)), c.roundedRate(),
+			),
+		})
+	}
+	return alerts, nil
+}
+
+// lowStockLevels returns the products whose total quantity across all lots,
+// and the groups whose total quantity across all members, is at or below
+// their threshold.
+func (s *AlertService) lowStockLevels(ctx context.Context) ([]stockLevel, error) {
+	levels, err := s.stockLevels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.groupLevels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	levels = append(levels, groups...)
+	var low []stockLevel
+	for _, l := range levels {
+		if l.low() {
+			low = append(low, l)
+		}
+	}
+	return low, nil
+}
+
+// stockLevels returns the stock level of every product in stock that is not
+// in a group. Watched products use their product-level minimum quantity as
+// the threshold and are included even without any stock left. target is the
+// product's target quantity, defaulting to one more than the threshold.
+func (s *AlertService) stockLevels(ctx context.Context) ([]stockLevel, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT ean, name, quantity, threshold, COALESCE(target_quantity, threshold + 1),
+		       amount, unit, COALESCE(package_size, 1), low_stock_amount
+		FROM (
+		    SELECT p.ean, p.name, p.target_quantity,
+		           p.unit, p.package_size, p.low_stock_amount,
+		           COALESCE(SUM(i.quantity), 0) AS quantity,
+		           COALESCE(SUM(i.amount), 0) AS amount,
+		           COALESCE(p.min_quantity, MAX(i.low_stock_threshold)) AS threshold
+		    FROM products p
+		    LEFT JOIN inventory i ON i.ean = p.ean
+		    WHERE p.group_id IS NULL
+		      AND (p.min_quantity IS NOT NULL OR i.id IS NOT NULL)
+		    GROUP BY p.ean
+		) stock
+		ORDER BY name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []stockLevel
+	for rows.Next() {
+		var l stockLevel
+		if err := rows.Scan(
+			&l.ean, &l.name, &l.quantity, &l.threshold, &l.target,
+			&l.amount, &l.unit, &l.size, &l.lowStockAmount,
+		); err != nil {
+			return nil, err
+		}
+		levels = append(levels, l)
+	}
+	return levels, rows.Err()
+}
+
+// groupLevels returns the stock level of every product group with at least
+// one member, counted in packages across all members. The group minimum
+// quantity is the threshold and the target is one more. The member to
+// restock is the one most recently added to inventory.
+func (s *AlertService) groupLevels(ctx context.Context) ([]stockLevel, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT g.id, g.name, g.min_quantity, COALESCE(SUM(i.quantity), 0),
+		       (SELECT m.ean
+		        FROM products m
+		        LEFT JOIN inventory_events e ON e.ean = m.ean AND e.source = 'add'
+		        WHERE m.group_id = g.id
+		        GROUP BY m.ean
+		        ORDER BY MAX(e.occurred_at) DESC NULLS LAST, m.ean
+		        LIMIT 1)
+		FROM product_groups g
+		JOIN products p ON p.group_id = g.id
+		LEFT JOIN inventory i ON i.ean = p.ean
+		GROUP BY g.id
+		ORDER BY g.name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []stockLevel
+	for rows.Next() {
+		var (
+			l  = stockLevel{unit: model.UnitPieces, size: 1}
+			id int
+		)
+		if err := rows.Scan(&id, &l.name, &l.threshold, &l.quantity, &l.ean); err != nil {
+			return nil, err
+		}
+		l.groupID = &id
+		l.target = l.threshold + 1
+		l.amount = float64(l.quantity)
+		levels = append(levels, l)
+	}
+	return levels, rows.Err()
+}
+
+// expiryAlerts returns one expiry_soon alert per lot whose expiry date falls
+// within the warning window.
+func (s *AlertService) expiryAlerts(ctx context.Context, expiryWarningDays int) ([]model.Alert, error) {
 	rows, err := s.db.Query(ctx, `
-		SELECT i.ean, p.name, i.quantity, i.low_stock_threshold,
+		SELECT i.id, i.ean, p.name, i.quantity,
 		       TO_CHAR(i.expiry_date, 'YYYY-MM-DD')
 		FROM inventory i
-		JOIN products p ON p.ean = i.ean`,
+		JOIN products p ON p.ean = i.ean
+		WHERE i.expiry_date IS NOT NULL
+		ORDER BY i.expiry_date, p.name, i.id`,
 	)
 	if err != nil {
 		return nil, err
@@ -46,40 +300,82 @@ func (s *AlertService) List(ctx context.Context) ([]model.Alert, error) {
 
 	for rows.Next() {
 		var (
+			id         int
 			ean, name  string
 			quantity   int
-			threshold  int
-			expiryDate *string
+			expiryDate string
 		)
-		if err := rows.Scan(&ean, &name, &quantity, &threshold, &expiryDate); err != nil {
+		if err := rows.Scan(&id, &ean, &name, &quantity, &expiryDate); err != nil {
 			return nil, err
 		}
 
-		if quantity <= threshold {
-			alerts = append(alerts, model.Alert{
-				Type:        model.AlertLowStock,
-				EAN:         ean,
-				ProductName: name,
-				Detail: fmt.Sprintf(
-					"Only %d item(s) left (threshold: %d)", quantity, threshold,
-				),
-			})
+		expiry, err := time.Parse("2006-01-02", expiryDate)
+		if err != nil || expiry.After(warnBefore) {
+			continue
 		}
+		daysLeft := int(time.Until(expiry).Hours()/24) + 1
+		alerts = append(alerts, model.Alert{
+			Type:        model.AlertExpirySoon,
+			EAN:         ean,
+			InventoryID: &id,
+			ProductName: name,
+			Detail: fmt.Sprintf(
+				"%d item(s) expire in %d day(s) (%s)", quantity, daysLeft, expiryDate,
+			),
+		})
+	}
+	return alerts, rows.Err()
+}
 
-		if expiryDate != nil {
-			expiry, err := time.Parse("2006-01-02", *expiryDate)
-			if err == nil && !expiry.After(warnBefore) {
-				daysLeft := int(time.Until(expiry).Hours()/24) + 1
-				alerts = append(alerts, model.Alert{
-					Type:        model.AlertExpirySoon,
-					EAN:         ean,
-					ProductName: name,
-					Detail: fmt.Sprintf(
-						"Expires in %d day(s) (%s)", daysLeft, *expiryDate,
-					),
-				})
-			}
+// openedExpiringAlerts returns one opened_expiring alert per lot of opened
+// units whose use-by date (opened date plus the product's days after
+// opening) falls within the warning window. Lots whose printed expiry date
+// comes first are left to expiry_soon.
+func (s *AlertService) openedExpiringAlerts(ctx context.Context, expiryWarningDays int) ([]model.Alert, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT i.id, i.ean, p.name, i.quantity,
+		       TO_CHAR(i.opened_at, 'YYYY-MM-DD'),
+		       TO_CHAR(i.opened_at + p.days_after_opening, 'YYYY-MM-DD')
+		FROM inventory i
+		JOIN products p ON p.ean = i.ean
+		WHERE i.opened_at IS NOT NULL
+		  AND p.days_after_opening IS NOT NULL
+		  AND i.opened_at + p.days_after_opening <= CURRENT_DATE + $1::int
+		  AND (i.expiry_date IS NULL
+		       OR i.opened_at + p.days_after_opening < i.expiry_date)
+		ORDER BY i.opened_at + p.days_after_opening, p.name, i.id`,
+		expiryWarningDays,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []model.Alert{}
+	for rows.Next() {
+		var (
+			id              int
+			ean, name       string
+			quantity        int
+			openedAt, useBy string
+		)
+		if err := rows.Scan(&id, &ean, &name, &quantity, &openedAt, &useBy); err != nil {
+			return nil, err
 		}
+		alerts = append(alerts, model.Alert{
+			Type:        model.AlertOpenedExpiring,
+			EAN:         ean,
+			InventoryID: &id,
+			ProductName: name,
+			Detail: fmt.Sprintf(
+				"%d opened item(s) should be used by %s (opened %s)", quantity, useBy, openedAt,
+			),
+		})
 	}
 	return alerts, rows.Err()
 }
+
+// formatAmount renders an amount with its unit, e.g. "250 g".
+func formatAmount(amount float64, unit model.Unit) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64) + " " + string(unit)
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/jackc/pgx/v5"
+
+	"foodinventory/internal/model"
+)
+
+// Sentinel errors of alias and merge operations, mapped to HTTP status codes
+// in the handler layer.
+var (
+	ErrAliasNotFound = errors.New("alias not found")
+	ErrAliasConflict = errors.New("code is already in use by another product")
+	ErrUnitMismatch  = errors.New("products are measured in different units")
+	ErrMergeSelf     = errors.New("a product cannot be merged into itself")
+)
+
+// canonicalEAN returns the product ean refers to: the product it is an alias
+// of, or ean itself.
+func canonicalEAN(ctx context.Context, q querier, ean string) (string, error) {
+	var target string
+	err := q.QueryRow(ctx,
+		`SELECT ean FROM product_aliases WHERE alias = $1`, ean,
+	).Scan(&target)
+	if err == pgx.ErrNoRows {
+		return ean, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// AddAlias makes alias a further barcode of ean; scans of alias then book
+// stock on ean. Adding an existing alias of ean again is a no-op. ean may
+// be an alias itself; the alias is added to its product.
+// Returns ErrProductNotFound when ean is unknown and ErrAliasConflict when
+// alias is a product of its own (merge it instead) or an alias of another
+// product.
+func (s *ProductService) AddAlias(ctx context.Context, ean, alias string) (*model.Product, error) {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockProducts(ctx, tx, ean); err != nil {
+		return nil, err
+	}
+	var exists bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM products WHERE ean = $1)`, alias,
+	).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrAliasConflict
+	}
+
+	var target string
+	err = tx.QueryRow(ctx,
+		`INSERT INTO product_aliases (alias, ean) VALUES ($1, $2)
+		 ON CONFLICT (alias) DO UPDATE SET alias = EXCLUDED.alias
+		 RETURNING ean`,
+		alias, ean,
+	).Scan(&target)
+	if err != nil {
+		return nil, err
+	}
+	if target != ean {
+		return nil, ErrAliasConflict
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.get(ctx, ean)
+}
+
+// RemoveAlias detaches alias from ean, which may be given by any of its
+// codes. Later scans of alias are resolved as a product of their own.
+// Returns ErrAliasNotFound when alias is not an alias of ean.
+func (s *ProductService) RemoveAlias(ctx context.Context, ean, alias string) error {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return err
+	}
+	tag, err := s.db.Exec(ctx,
+		`DELETE FROM product_aliases WHERE alias = $1 AND ean = $2`, alias, ean,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAliasNotFound
+	}
+	return nil
+}
+
+// Merge merges the product from into ean in one transaction: the lots of from
+// are moved to ean (amounts are rescaled to ean's package size, so partly used
+// packages stay partly used), its shopping list items and aliases are taken
+// over, settings that ean lacks are copied, and from becomes an alias of ean.
+// The lots of both products share the higher low-stock threshold afterwards.
+// The moved stock is recorded in the ledger. Aliases of both are followed.
+// Returns ErrProductNotFound when either product is unknown, ErrMergeSelf
+// when both are the same product, ErrUnitMismatch when they are measured in
+// different units, as their amounts could not be converted, and
+// ErrContentsCycle when the merged product would contain itself through the
+// package contents of both.
+func (s *ProductService) Merge(ctx context.Context, ean, from string) (*model.Product, error) {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, err
+	}
+	from, err = canonicalEAN(ctx, s.db, from)
+	if err != nil {
+		return nil, err
+	}
+	if from == ean {
+		return nil, ErrMergeSelf
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockProducts(ctx, tx, ean, from); err != nil {
+		return nil, err
+	}
+	unit, size, err := productMeasure(ctx, tx, ean)
+	if err != nil {
+		return nil, err
+	}
+	fromUnit, fromSize, err := productMeasure(ctx, tx, from)
+	if err != nil {
+		return nil, err
+	}
+	if unit != fromUnit {
+		return nil, ErrUnitMismatch
+	}
+
+	lots, err := lockLots(ctx, tx, from, `TRUE`)
+	if err != nil {
+		return nil, err
+	}
+	var threshold int
+	err = tx.QueryRow(ctx,
+		`SELECT COALESCE(MAX(low_stock_threshold), 0) FROM inventory WHERE ean = ANY($1)`,
+		[]string{ean, from},
+	).Scan(&threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lots) > 0 {
+		opID, err := newOperationID(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range lots {
+			amount := max(math.Round(l.amount*size/fromSize*1000)/1000, 0.001)
+			to, _, err := addToLot(ctx, tx, ean, l.expiryDate, l.batch, l.locationID, l.openedAt, l.quantity, amount)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := takeFromLot(ctx, tx, l, l.quantity); err != nil {
+				return nil, err
+			}
+
+			events := []stockEvent{
+				{operationID: opID, lot: l, delta: -l.quantity, amountDelta: -l.amount, source: model.EventMerge},
+				{operationID: opID, lot: to, delta: l.quantity, amountDelta: amount, source: model.EventMerge},
+			}
+			for _, ev := range events {
+				if err := recordEvent(ctx, tx, ev); err != nil {
+					return nil, err
+				}
+			}
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE inventory SET low_stock_threshold = $2 WHERE ean = $1`, ean, threshold,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	statements := []string{
+		`UPDATE products AS p
+		 SET min_quantity       = COALESCE(p.min_quantity, f.min_quantity),
+		     target_quantity    = COALESCE(p.target_quantity, f.target_quantity),
+		     days_after_opening = COALESCE(p.days_after_opening, f.days_after_opening),
+		     group_id           = COALESCE(p.group_id, f.group_id),
+		     contains_ean       = CASE WHEN p.contains_ean IS NULL AND f.contains_ean <> p.ean
+		                               THEN f.contains_ean ELSE p.contains_ean END,
+		     contains_quantity  = CASE WHEN p.contains_ean IS NULL AND f.contains_ean <> p.ean
+		                               THEN f.contains_quantity ELSE p.contains_quantity END,
+		     resolved           = p.resolved OR f.resolved
+		 FROM products f
+		 WHERE p.ean = $1 AND f.ean = $2`,
+		// A package of from that ean was declared to contain is now ean itself.
+		`UPDATE products SET contains_ean = NULL, contains_quantity = NULL
+		 WHERE ean = $1 AND contains_ean = $2`,
+		`UPDATE products SET contains_ean = $1 WHERE contains_ean = $2`,
+		`UPDATE shopping_list_items SET ean = $1 WHERE ean = $2`,
+		`UPDATE product_aliases SET ean = $1 WHERE ean = $2`,
+		`DELETE FROM products WHERE ean = $2`,
+		`INSERT INTO product_aliases (alias, ean) VALUES ($2, $1)`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(ctx, stmt, ean, from); err != nil {
+			return nil, err
+		}
+	}
+	// Repointed contents only form a cycle through ean, e.g. when ean
+	// contained a package of from.
+	var inner *string
+	err = tx.QueryRow(ctx, `SELECT contains_ean FROM products WHERE ean = $1`, ean).Scan(&inner)
+	if err != nil {
+		return nil, err
+	}
+	if inner != nil {
+		cycle, err := containsPath(ctx, tx, *inner, ean)
+		if err != nil {
+			return nil, err
+		}
+		if cycle {
+			return nil, ErrContentsCycle
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.get(ctx, ean)
+}
+
+// lockProducts locks the products rows of eans until tx ends, in a fixed
+// order so concurrent merges cannot deadlock. Returns ErrProductNotFound
+// when any of them is unknown.
+func lockProducts(ctx context.Context, tx pgx.Tx, eans ...string) error {
+	rows, err := tx.Query(ctx,
+		`SELECT ean FROM products WHERE ean = ANY($1) ORDER BY ean FOR UPDATE`, eans,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := 0
+	for rows.Next() {
+		found++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if found < len(eans) {
+		return ErrProductNotFound
+	}
+	return nil
+}
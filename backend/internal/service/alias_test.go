@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"foodinventory/internal/model"
+)
+
+func TestAliasAddressesProduct(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	products := NewProductService(pool, nil, 0, nil)
+
+	ean := createProduct(t, products, "Spaghetti")
+	alias := createProduct(t, products, "Spaghetti (regional label)")
+	if _, err := products.Merge(ctx, ean, alias); err != nil {
+		t.Fatal(err)
+	}
+
+	target, days := 4, 3
+	p, err := products.SetTargetQuantity(ctx, alias, &target)
+	if err != nil {
+		t.Fatalf("SetTargetQuantity through alias: %v", err)
+	}
+	if p.EAN != ean || p.TargetQuantity == nil || *p.TargetQuantity != target {
+		t.Errorf("SetTargetQuantity through alias = %s with target %v, want %s with %d",
+			p.EAN, p.TargetQuantity, ean, target)
+	}
+	if _, err := products.SetDaysAfterOpening(ctx, alias, &days); err != nil {
+		t.Errorf("SetDaysAfterOpening through alias: %v", err)
+	}
+	size := 500.0
+	measure := model.MeasureRequest{Unit: model.UnitGrams, PackageSize: &size}
+	if _, err := products.SetMeasure(ctx, alias, measure); err != nil {
+		t.Errorf("SetMeasure through alias: %v", err)
+	}
+	if _, err := products.Watch(ctx, alias, 1); err != nil {
+		t.Errorf("Watch through alias: %v", err)
+	}
+	if err := products.Unwatch(ctx, alias); err != nil {
+		t.Errorf("Unwatch through alias: %v", err)
+	}
+	if err := products.UpdateProduct(ctx, alias, "Spaghetti No. 5", nil); err != nil {
+		t.Errorf("UpdateProduct through alias: %v", err)
+	}
+
+	p, err = products.Product(ctx, ean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Spaghetti No. 5" || p.DaysAfterOpening == nil || *p.DaysAfterOpening != days ||
+		p.Unit != model.UnitGrams || p.MinQuantity != nil {
+		t.Errorf("product after updates through alias = %+v", p)
+	}
+
+	if _, err := products.Merge(ctx, ean, alias); !errors.Is(err, ErrMergeSelf) {
+		t.Errorf("Merge of an alias into its product: error = %v, want %v", err, ErrMergeSelf)
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"foodinventory/internal/model"
+)
+
+// Sentinel errors of package contents, mapped to HTTP status codes in the
+// handler layer.
+var (
+	ErrContentsCycle = errors.New("package would contain itself")
+	ErrPackTooDeep   = errors.New("packages are nested too deeply")
+)
+
+// maxPackDepth bounds how many levels of packages may be nested, such as a
+// pallet of cases of bottles.
+const maxPackDepth = 8
+
+// Product returns the cached product for ean, following aliases. Returns
+// ErrProductNotFound when ean is unknown.
+func (s *ProductService) Product(ctx context.Context, ean string) (*model.Product, error) {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, err
+	}
+	return s.get(ctx, ean)
+}
+
+// SetContents declares that one package of ean contains quantity units of
+// inner; adding or removing ean then books the contained units on inner.
+// Both products are resolved first and aliases are followed. Packages may be
+// nested up to maxPackDepth levels. Returns ErrContentsCycle when inner is,
+// or contains, ean, and ErrPackTooDeep when the nesting would be deeper.
+func (s *ProductService) SetContents(ctx context.Context, ean, inner string, quantity int) (*model.Product, error) {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, err
+	}
+	inner, err = canonicalEAN(ctx, s.db, inner)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range []string{ean, inner} {
+		if err := s.Ensure(ctx, code); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the package and the products inner leads to before the check, so
+	// a concurrent change cannot close a cycle between check and update.
+	if _, err := tx.Exec(ctx, `
+		WITH RECURSIVE chain (ean) AS (
+		    SELECT $1::varchar
+		    UNION
+		    SELECT p.contains_ean FROM products p JOIN chain c ON p.ean = c.ean
+		    WHERE p.contains_ean IS NOT NULL
+		)
+		SELECT ean FROM products
+		WHERE ean = $2 OR ean IN (SELECT ean FROM chain)
+		ORDER BY ean FOR UPDATE`,
+		inner, ean,
+	); err != nil {
+		return nil, err
+	}
+	cycle, err := containsPath(ctx, tx, inner, ean)
+	if err != nil {
+		return nil, err
+	}
+	if cycle {
+		return nil, ErrContentsCycle
+	}
+	// Levels below ean through inner, plus levels of packages above ean.
+	var depth int
+	err = tx.QueryRow(ctx, `
+		WITH RECURSIVE down (ean, depth) AS (
+		    SELECT $1::varchar, 1
+		    UNION ALL
+		    SELECT p.contains_ean, d.depth + 1 FROM products p JOIN down d ON p.ean = d.ean
+		    WHERE p.contains_ean IS NOT NULL AND d.depth <= $3
+		), up (ean, depth) AS (
+		    SELECT $2::varchar, 0
+		    UNION ALL
+		    SELECT p.ean, u.depth + 1 FROM products p JOIN up u ON p.contains_ean = u.ean
+		    WHERE u.depth <= $3
+		)
+		SELECT (SELECT max(depth) FROM down) + (SELECT max(depth) FROM up)`,
+		inner, ean, maxPackDepth,
+	).Scan(&depth)
+	if err != nil {
+		return nil, err
+	}
+	if depth > maxPackDepth {
+		return nil, ErrPackTooDeep
+	}
+
+	var p model.Product
+	err = tx.QueryRow(ctx,
+		`UPDATE products AS p SET contains_ean = $2, contains_quantity = $3
+		 WHERE ean = $1
+		 RETURNING `+productColumns,
+		ean, inner, quantity,
+	).Scan(productFields(&p)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// containsPath reports whether from is to, or a package of from contains to
+// directly or nested.
+func containsPath(ctx context.Context, q querier, from, to string) (bool, error) {
+	var found bool
+	err := q.QueryRow(ctx, `
+		WITH RECURSIVE chain (ean) AS (
+		    SELECT $1::varchar
+		    UNION
+		    SELECT p.contains_ean FROM products p JOIN chain c ON p.ean = c.ean
+		    WHERE p.contains_ean IS NOT NULL
+		)
+		SELECT EXISTS (SELECT 1 FROM chain WHERE ean = $2)`,
+		from, to,
+	).Scan(&found)
+	return found, err
+}
+
+// ClearContents makes ean a plain product again that is booked as one
+// package; aliases are followed. Returns ErrProductNotFound when the EAN is
+// unknown.
+func (s *ProductService) ClearContents(ctx context.Context, ean string) error {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return err
+	}
+	tag, err := s.db.Exec(ctx,
+		`UPDATE products SET contains_ean = NULL, contains_quantity = NULL
+		 WHERE ean = $1`, ean,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+// unpack returns the product and number of units that n packages of ean
+// stand for: the innermost product they contain, or ean itself when it is
+// not a package. Returns ErrPackTooDeep when the packages are nested deeper
+// than maxPackDepth levels, which also stops at a cycle.
+func unpack(ctx context.Context, q querier, ean string, n int) (string, int, error) {
+	for depth := 0; ; depth++ {
+		var (
+			inner    *string
+			quantity *int
+		)
+		err := q.QueryRow(ctx,
+			`SELECT contains_ean, contains_quantity FROM products WHERE ean = $1`, ean,
+		).Scan(&inner, &quantity)
+		if err == pgx.ErrNoRows || (err == nil && inner == nil) {
+			return ean, n, nil
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		if depth == maxPackDepth {
+			return "", 0, ErrPackTooDeep
+		}
+		ean, n = *inner, n**quantity
+	}
+}
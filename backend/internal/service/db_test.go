@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"foodinventory/internal/db"
+	"foodinventory/internal/model"
+)
+
+// testPool returns a pool on a fresh, migrated schema of the database at
+// TEST_DATABASE_URL; the schema is dropped when the test ends. Tests that
+// need a database are skipped when the variable is not set.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	if err := db.RunMigrations(ctx, pool); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// createProduct adds a resolved product under an internal code, which is
+// never looked up.
+func createProduct(t *testing.T, products *ProductService, name string) string {
+	t.Helper()
+	p, err := products.Create(context.Background(), model.CreateProductRequest{Name: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.EAN
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"foodinventory/internal/model"
+)
+
+// consumptionLookback is how far back removals are taken into account for
+// the consumption rate, so the forecast follows changing habits.
+const consumptionLookback = 90 * 24 * time.Hour
+
+// consumptionSources lists the operations whose removed units count as
+// consumption: removals and the surplus found by a recount. Moves only
+// relocate stock, and undone operations are skipped.
+var consumptionSources = []string{string(model.EventRemove), string(model.EventSet)}
+
+// consumption is the forecast input for one product.
+type consumption struct {
+	rate  float64 // units per day
+	stock int     // current total across all lots
+}
+
+// consumptionRates returns the consumption of the products in eans (all
+// products when eans is nil) that had units removed within the lookback
+// window. The rate is the number of units removed divided by the days
+// observed: the lookback window, or the time since the product's first
+// ledger event when it is younger, but at least one day. Units are counted
+// by amount, in packages of the product's package size, so amounts taken
+// from an opened package count as the fraction of a package they are.
+// Events recorded under an alias count for the alias's product.
+func consumptionRates(ctx context.Context, db *pgxpool.Pool, eans []string) (map[string]consumption, error) {
+	now := time.Now()
+	windowStart := now.Add(-consumptionLookback)
+
+	rows, err := db.Query(ctx, `
+		WITH events AS (
+		    SELECT COALESCE(a.ean, e.ean) AS ean, e.operation_id, e.occurred_at,
+		           e.amount_delta, e.source
+		    FROM inventory_events e
+		    LEFT JOIN product_aliases a ON a.alias = e.ean
+		),
+		consumed AS (
+		    SELECT e.ean, SUM(-e.amount_delta) AS amount
+		    FROM events e
+		    WHERE e.occurred_at >= $1
+		      AND e.amount_delta < 0
+		      AND e.source = ANY($2)
+		      AND ($3::text[] IS NULL OR e.ean = ANY($3))
+		      AND NOT EXISTS (
+		          SELECT 1 FROM inventory_events u
+		          WHERE u.reverts_operation_id = e.operation_id)
+		    GROUP BY e.ean
+		)
+		SELECT c.ean, (c.amount / COALESCE(p.package_size, 1))::float8,
+		       (SELECT MIN(f.occurred_at) FROM events f WHERE f.ean = c.ean),
+		       (SELECT COALESCE(SUM(i.quantity), 0) FROM inventory i WHERE i.ean = c.ean)
+		FROM consumed c
+		JOIN products p ON p.ean = c.ean`,
+		windowStart, consumptionSources, eans,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := map[string]consumption{}
+	for rows.Next() {
+		var (
+			ean        string
+			units      float64
+			firstEvent time.Time
+			c          consumption
+		)
+		if err := rows.Scan(&ean, &units, &firstEvent, &c.stock); err != nil {
+			return nil, err
+		}
+		start := windowStart
+		if firstEvent.After(start) {
+			start = firstEvent
+		}
+		days := max(now.Sub(start).Hours()/24, 1)
+		c.rate = units / days
+		rates[ean] = c
+	}
+	return rates, rows.Err()
+}
+
+// daysUntil returns the projected number of days until the stock has
+// dropped by packages at the consumption rate.
+func (c consumption) daysUntil(packages float64) float64 {
+	return packages / c.rate
+}
+
+// depletionDate returns the projected date (YYYY-MM-DD) on which the stock
+// runs out.
+func (c consumption) depletionDate() string {
+	days := c.daysUntil(float64(c.stock))
+	return time.Now().Add(time.Duration(days * 24 * float64(time.Hour))).Format("2006-01-02")
+}
+
+// roundedRate returns the rate rounded to two decimals for display.
+func (c consumption) roundedRate() float64 {
+	return math.Round(c.rate*100) / 100
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"foodinventory/internal/model"
+)
+
+// Sentinel errors of product groups, mapped to HTTP status codes in the
+// handler layer.
+var (
+	ErrGroupNotFound  = errors.New("group not found")
+	ErrGroupExists    = errors.New("group name already exists")
+	ErrNotGroupMember = errors.New("product is not a member of the group")
+)
+
+// GroupService manages product groups: sets of interchangeable products whose
+// stock alerts are raised for the group as a whole.
+type GroupService struct {
+	db         *pgxpool.Pool
+	productSvc *ProductService
+}
+
+func NewGroupService(db *pgxpool.Pool, productSvc *ProductService) *GroupService {
+	return &GroupService{db: db, productSvc: productSvc}
+}
+
+// List returns all groups with their members, ordered by name.
+func (s *GroupService) List(ctx context.Context) ([]model.ProductGroup, error) {
+	return s.list(ctx, nil)
+}
+
+// Create adds a new group without members. Returns ErrGroupExists when the
+// name is already taken.
+func (s *GroupService) Create(ctx context.Context, req model.GroupRequest) (*model.ProductGroup, error) {
+	g := model.ProductGroup{Name: req.Name, MinQuantity: req.MinQuantity, Members: []model.Product{}}
+	err := s.db.QueryRow(ctx,
+		`INSERT INTO product_groups (name, min_quantity) VALUES ($1, $2) RETURNING id`,
+		req.Name, req.MinQuantity,
+	).Scan(&g.ID)
+	if isPgError(err, pgUniqueViolation) {
+		return nil, ErrGroupExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// Update changes the name and minimum quantity of a group.
+func (s *GroupService) Update(ctx context.Context, id int, req model.GroupRequest) (*model.ProductGroup, error) {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE product_groups SET name = $2, min_quantity = $3 WHERE id = $1`,
+		id, req.Name, req.MinQuantity,
+	)
+	if isPgError(err, pgUniqueViolation) {
+		return nil, ErrGroupExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrGroupNotFound
+	}
+	return s.get(ctx, id)
+}
+
+// Delete removes a group. Its members are kept and fall back to their own
+// low-stock thresholds.
+func (s *GroupService) Delete(ctx context.Context, id int) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM product_groups WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+// AddMember puts the product into the group, taking it out of any other
+// group. The product is resolved first, so products that are not in stock
+// can be added too; aliases are followed.
+func (s *GroupService) AddMember(ctx context.Context, id int, ean string) (*model.ProductGroup, error) {
+	if _, err := s.get(ctx, id); err != nil {
+		return nil, err
+	}
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.productSvc.Ensure(ctx, ean); err != nil {
+		return nil, err
+	}
+	_, err = s.db.Exec(ctx, `UPDATE products SET group_id = $2 WHERE ean = $1`, ean, id)
+	if isPgError(err, pgForeignKeyViolation) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.get(ctx, id)
+}
+
+// RemoveMember takes the product out of the group. Returns ErrNotGroupMember
+// when it is not a member.
+func (s *GroupService) RemoveMember(ctx context.Context, id int, ean string) error {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return err
+	}
+	tag, err := s.db.Exec(ctx,
+		`UPDATE products SET group_id = NULL WHERE ean = $1 AND group_id = $2`, ean, id,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotGroupMember
+	}
+	return nil
+}
+
+// get returns a single group. Returns ErrGroupNotFound when id is unknown.
+func (s *GroupService) get(ctx context.Context, id int) (*model.ProductGroup, error) {
+	groups, err := s.list(ctx, &id)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, ErrGroupNotFound
+	}
+	return &groups[0], nil
+}
+
+// list returns the group with the given id, or all groups when id is nil.
+func (s *GroupService) list(ctx context.Context, id *int) ([]model.ProductGroup, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT g.id, g.name, g.min_quantity,
+		       COALESCE((SELECT SUM(i.quantity)
+		                 FROM inventory i
+		                 JOIN products p ON p.ean = i.ean
+		                 WHERE p.group_id = g.id), 0)
+		FROM product_groups g
+		WHERE $1::int IS NULL OR g.id = $1
+		ORDER BY g.name`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []model.ProductGroup{}
+	index := map[int]int{}
+	for rows.Next() {
+		g := model.ProductGroup{Members: []model.Product{}}
+		if err := rows.Scan(&g.ID, &g.Name, &g.MinQuantity, &g.Quantity); err != nil {
+			return nil, err
+		}
+		index[g.ID] = len(groups)
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(ctx, `
+		SELECT `+productColumns+`
+		FROM products p
+		WHERE p.group_id IS NOT NULL AND ($1::int IS NULL OR p.group_id = $1)
+		ORDER BY p.name, p.ean`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p model.Product
+		if err := rows.Scan(productFields(&p)...); err != nil {
+			return nil, err
+		}
+		if i, ok := index[*p.GroupID]; ok {
+			groups[i].Members = append(groups[i].Members, p)
+		}
+	}
+	return groups, rows.Err()
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"foodinventory/internal/model"
+)
+
+// HistoryService reads the append-only stock movement ledger.
+type HistoryService struct {
+	db *pgxpool.Pool
+}
+
+func NewHistoryService(db *pgxpool.Pool) *HistoryService {
+	return &HistoryService{db: db}
+}
+
+// List returns ledger events matching f, newest first. Events recorded under
+// an alias of a product belong to the product; they keep their original EAN.
+func (s *HistoryService) List(ctx context.Context, f model.HistoryFilter) ([]model.InventoryEvent, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT e.id, e.operation_id, e.occurred_at, e.ean, COALESCE(p.name, e.ean),
+		       e.inventory_id, e.location_id, e.delta, e.amount_delta, e.quantity_after,
+		       e.source, e.reason, e.note, e.reverts_operation_id
+		FROM inventory_events e
+		LEFT JOIN product_aliases a ON a.alias = e.ean
+		LEFT JOIN products p ON p.ean = COALESCE(a.ean, e.ean)
+		WHERE ($1::text IS NULL OR e.ean = $1 OR a.ean = $1)
+		  AND ($2::timestamptz IS NULL OR e.occurred_at >= $2)
+		  AND ($3::timestamptz IS NULL OR e.occurred_at < $3)
+		ORDER BY e.occurred_at DESC, e.id DESC
+		LIMIT $4`,
+		f.EAN, f.From, f.To, f.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []model.InventoryEvent{}
+	for rows.Next() {
+		var e model.InventoryEvent
+		if err := rows.Scan(
+			&e.ID, &e.OperationID, &e.OccurredAt, &e.EAN, &e.ProductName,
+			&e.InventoryID, &e.LocationID, &e.Delta, &e.AmountDelta, &e.QuantityAfter,
+			&e.Source, &e.Reason, &e.Note, &e.RevertsOperationID,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// stockEvent is one entry to append to the inventory_events ledger.
+// lot is the state of the affected lot before the change; it is stored as a
+// snapshot so the change can be undone even after the lot was deleted.
+// Events without a lot (product updates) only set lot.ean. delta counts
+// packages and amountDelta the change in the product's unit.
+type stockEvent struct {
+	operationID int64
+	lot         lot
+	delta       int
+	amountDelta float64
+	source      model.EventSource
+	reason      *model.RemovalReason
+	note        *string
+	reverts     *int64
+}
+
+// newOperationID allocates the ID that groups the events of one operation.
+func newOperationID(ctx context.Context, tx pgx.Tx) (int64, error) {
+	var id int64
+	err := tx.QueryRow(ctx, `SELECT nextval('inventory_operation_seq')`).Scan(&id)
+	return id, err
+}
+
+// recordEvent appends ev to the ledger within tx. quantity_after is computed
+// from the inventory table, so recordEvent must run after the stock change.
+func recordEvent(ctx context.Context, tx pgx.Tx, ev stockEvent) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO inventory_events
+		     (operation_id, ean, inventory_id, location_id, expiry_date, batch, opened_at,
+		      low_stock_threshold, delta, amount_delta, quantity_after, source, reason,
+		      note, reverts_operation_id)
+		 VALUES ($1, $2, NULLIF($3, 0), $4, $5::date, $6, $7::date, NULLIF($8, 0), $9, $10,
+		         (SELECT COALESCE(SUM(quantity), 0) FROM inventory WHERE ean = $2),
+		         $11, $12, $13, $14)`,
+		ev.operationID, ev.lot.ean, ev.lot.id, ev.lot.locationID, ev.lot.expiryDate,
+		ev.lot.batch, ev.lot.openedAt, ev.lot.threshold, ev.delta, ev.amountDelta,
+		ev.source, ev.reason, ev.note, ev.reverts,
+	)
+	return err
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"math"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -11,143 +13,720 @@ import (
 )
 
 // Sentinel errors mapped to HTTP status codes in the handler layer.
-var ErrInventoryEntryNotFound = errors.New("inventory entry not found")
+var (
+	ErrInventoryEntryNotFound = errors.New("inventory entry not found")
+	ErrInsufficientStock      = errors.New("not enough units in stock")
+	ErrAlreadyOpened          = errors.New("inventory entry is already opened")
+)
+
+// entrySelect reads inventory lots together with their product and location.
+// Rows are decoded with scanEntry.
+const entrySelect = `
+	SELECT i.id, i.quantity, i.amount,
+	       TO_CHAR(i.expiry_date, 'YYYY-MM-DD'), i.batch,
+	       i.low_stock_threshold,
+	       TO_CHAR(i.opened_at, 'YYYY-MM-DD'),
+	       TO_CHAR(i.opened_at + p.days_after_opening, 'YYYY-MM-DD'),
+	       ` + productColumns + `,
+	       l.id, l.name
+	FROM inventory i
+	JOIN products p ON p.ean = i.ean
+	LEFT JOIN locations l ON l.id = i.location_id`
 
-// InventoryService manages stock CRUD operations.
+// InventoryService manages stock CRUD operations. Stock is stored as lots:
+// each inventory row holds the units of one EAN sharing an expiry date, batch
+// number and storage location.
 type InventoryService struct {
 	db         *pgxpool.Pool
 	productSvc *ProductService
+	undoWindow time.Duration
 }
 
-func NewInventoryService(db *pgxpool.Pool, productSvc *ProductService) *InventoryService {
-	return &InventoryService{db: db, productSvc: productSvc}
+// NewInventoryService creates the service. undoWindow is how long after an
+// operation it can still be undone.
+func NewInventoryService(
+	db *pgxpool.Pool, productSvc *ProductService, undoWindow time.Duration,
+) *InventoryService {
+	return &InventoryService{db: db, productSvc: productSvc, undoWindow: undoWindow}
 }
 
-// List returns all inventory entries ordered by product name.
+// List returns all inventory lots ordered by product name, then by expiry
+// date (lots without an expiry date last).
 func (s *InventoryService) List(ctx context.Context) ([]model.InventoryEntry, error) {
-	rows, err := s.db.Query(ctx, `
-		SELECT i.id, i.quantity,
-		       TO_CHAR(i.expiry_date, 'YYYY-MM-DD'),
-		       i.low_stock_threshold,
-		       p.ean, p.name, p.category, p.image_url, p.resolved
-		FROM inventory i
-		JOIN products p ON p.ean = i.ean
-		ORDER BY p.name`,
+	return s.query(ctx, entrySelect+`
+		ORDER BY p.name, i.expiry_date NULLS LAST, i.id`,
 	)
+}
+
+// Add adds req.Quantity units (default 1) of a product to inventory. Units
+// are grouped into lots by expiry date, batch number and location: they are
+// added to the matching lot, or a new lot is created for them. The change is
+// recorded in the ledger, and open shopping list items for the product are
+// checked off. Aliases are followed, and variable-measure codes are added
+// under their base item code; an embedded weight is added as the amount of
+// products measured in grams. Cases and multipacks are added as the units
+// they contain.
+// Returns the lot and true when a new row was created, false on increment.
+func (s *InventoryService) Add(
+	ctx context.Context, req model.AddProductRequest,
+) (*model.InventoryEntry, bool, error) {
+	if err := checkLocation(ctx, s.db, req.LocationID); err != nil {
+		return nil, false, err
+	}
+	ean, weight := s.productSvc.splitMeasure(req.EAN)
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := s.productSvc.Ensure(ctx, ean); err != nil {
+		return nil, false, err
+	}
+	inner, n, err := unpack(ctx, s.db, ean, unitsOrOne(req.Quantity))
+	if err != nil {
+		return nil, false, err
+	}
+	req.EAN = inner
+	if weight != nil && *weight > 0 {
+		if err := s.productSvc.weighByDefault(ctx, req.EAN, *weight); err != nil {
+			return nil, false, err
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	unit, size, err := productMeasure(ctx, tx, req.EAN)
+	if err != nil {
+		return nil, false, err
+	}
+	opID, err := newOperationID(ctx, tx)
+	if err != nil {
+		return nil, false, err
+	}
+	amount := float64(n) * size
+	if weight != nil && *weight > 0 && unit == model.UnitGrams {
+		amount = float64(n) * *weight
+	}
+	l, created, err := addToLot(ctx, tx, req.EAN, req.ExpiryDate, req.Batch, req.LocationID, nil, n, amount)
+	if err != nil {
+		return nil, false, err
+	}
+	err = recordEvent(ctx, tx, stockEvent{
+		operationID: opID,
+		lot:         l,
+		delta:       n,
+		amountDelta: amount,
+		source:      model.EventAdd,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	for _, code := range []string{req.EAN, ean} {
+		if err := checkOffShoppingItems(ctx, tx, code); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, err
+	}
+
+	entry, err := s.getByID(ctx, l.id)
+	return entry, created, err
+}
+
+// Remove takes req.Quantity units (default 1) of ean out of stock, draining
+// the lots that expire first (FIFO); lots without an expiry date are used
+// last. When req.LocationID is set only lots stored at that location are
+// considered. Nothing is removed when fewer units are in stock. The change
+// is recorded in the ledger together with req.Reason and req.Note.
+// Aliases are followed, and variable-measure codes refer to their base item
+// code; without a quantity or amount, the embedded weight is removed.
+// Cases and multipacks take out the units they contain.
+// Returns the last lot taken from, or nil when it reached 0 and was deleted.
+func (s *InventoryService) Remove(
+	ctx context.Context, ean string, req model.RemoveProductRequest,
+) (*model.InventoryEntry, error) {
+	ean, weight := s.productSvc.splitMeasure(ean)
+
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback(ctx)
 
-	entries := []model.InventoryEntry{}
-	for rows.Next() {
-		var e model.InventoryEntry
-		if err := rows.Scan(
-			&e.ID, &e.Quantity, &e.ExpiryDate, &e.LowStockThreshold,
-			&e.Product.EAN, &e.Product.Name, &e.Product.Category, &e.Product.ImageURL, &e.Product.Resolved,
-		); err != nil {
+	ean, err = canonicalEAN(ctx, tx, ean)
+	if err != nil {
+		return nil, err
+	}
+	inner, n, err := unpack(ctx, tx, ean, unitsOrOne(req.Quantity))
+	if err != nil {
+		return nil, err
+	}
+	if inner != ean {
+		ean, req.Quantity = inner, &n
+	}
+
+	if err := checkLocation(ctx, tx, req.LocationID); err != nil {
+		return nil, err
+	}
+
+	lots, err := lockLots(ctx, tx, ean,
+		`$2::int IS NULL OR location_id = $2`, req.LocationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(lots) == 0 {
+		return nil, ErrInventoryEntryNotFound
+	}
+	if weight != nil && *weight > 0 && req.Quantity == nil && req.Amount == nil {
+		// A scale label takes out the weight printed on it, as long as the
+		// product is measured by weight.
+		unit, _, err := productMeasure(ctx, tx, ean)
+		if err != nil {
 			return nil, err
 		}
-		entries = append(entries, e)
+		if unit == model.UnitGrams {
+			req.Amount, req.Unit = weight, nil
+		}
+	}
+
+	opID, err := newOperationID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := stockEvent{
+		operationID: opID,
+		source:      model.EventRemove,
+		reason:      req.Reason,
+		note:        req.Note,
+	}
+	var (
+		last    lot
+		emptied bool
+	)
+	if req.Amount != nil {
+		var unit model.Unit
+		unit, _, err = productMeasure(ctx, tx, ean)
+		if err != nil {
+			return nil, err
+		}
+		var amount float64
+		amount, err = toProductUnit(*req.Amount, req.Unit, unit)
+		if err != nil {
+			return nil, err
+		}
+		last, emptied, err = takeAmountFIFO(ctx, tx, lots, amount, tmpl)
+	} else {
+		last, emptied, err = takeFIFO(ctx, tx, lots, unitsOrOne(req.Quantity), tmpl)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return entries, rows.Err()
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	if emptied {
+		return nil, nil
+	}
+	return s.getByID(ctx, last.id)
 }
 
-// Add adds a product to inventory or increments its quantity.
-// Returns the entry and true when a new row was created, false on increment.
-func (s *InventoryService) Add(
-	ctx context.Context, req model.AddProductRequest,
-) (*model.InventoryEntry, bool, error) {
-	product, err := s.productSvc.GetOrFetch(ctx, req.EAN)
-	if err != nil && !errors.Is(err, ErrFetchTimeout) {
-		return nil, false, err
+// SetQuantity sets the stock of ean to an absolute count after a manual
+// recount. When req.LocationID is set only stock at that location is
+// counted and changed. Surplus units are removed FIFO; missing units are
+// added to the lot that expires last (the most recently bought), or to a new
+// lot without expiry date when there is none. A count of 0 deletes all
+// affected lots.
+// Returns all lots of ean afterwards.
+func (s *InventoryService) SetQuantity(
+	ctx context.Context, ean string, req model.SetQuantityRequest,
+) ([]model.InventoryEntry, error) {
+	if err := checkLocation(ctx, s.db, req.LocationID); err != nil {
+		return nil, err
+	}
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, err
+	}
+	target := *req.Quantity
+	if target > 0 {
+		if err := s.productSvc.Ensure(ctx, ean); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if product == nil {
-		// EAN not found in external API or fetch timed out — insert a stub
-		// row using the EAN as the name with resolved = false.
-		if stubErr := s.productSvc.InsertStub(ctx, req.EAN); stubErr != nil {
-			return nil, false, stubErr
+	defer tx.Rollback(ctx)
+
+	lots, err := lockLots(ctx, tx, ean,
+		`$2::int IS NULL OR location_id = $2`, req.LocationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	current := 0
+	for _, l := range lots {
+		current += l.quantity
+	}
+
+	opID, err := newOperationID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case target < current:
+		_, _, err = takeFIFO(ctx, tx, lots, current-target, stockEvent{
+			operationID: opID,
+			source:      model.EventSet,
+		})
+	case target > current:
+		var expiryDate *string
+		if len(lots) > 0 {
+			expiryDate = lots[len(lots)-1].expiryDate
+		}
+		var (
+			l    lot
+			size float64
+		)
+		n := target - current
+		_, size, err = productMeasure(ctx, tx, ean)
+		if err == nil {
+			l, _, err = addToLot(ctx, tx, ean, expiryDate, nil, req.LocationID, nil, n, float64(n)*size)
 		}
+		if err == nil {
+			err = recordEvent(ctx, tx, stockEvent{
+				operationID: opID,
+				lot:         l,
+				delta:       n,
+				amountDelta: float64(n) * size,
+				source:      model.EventSet,
+			})
+		}
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	var id int
-	err = s.db.QueryRow(ctx,
-		`SELECT id FROM inventory WHERE ean = $1`, req.EAN,
-	).Scan(&id)
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.listByEAN(ctx, ean)
+}
 
+// UpdateEntry changes the editable fields of lot id. The low-stock threshold
+// applies to the product as a whole, so it is updated on all lots of the
+// same EAN. A new amount is recorded in the ledger like a recount.
+// Returns ErrInventoryEntryNotFound for an unknown id.
+func (s *InventoryService) UpdateEntry(
+	ctx context.Context, id int, req model.UpdateInventoryEntryRequest,
+) (*model.InventoryEntry, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	l, err := scanLot(tx.QueryRow(ctx,
+		`SELECT `+lotColumns+` FROM inventory WHERE id = $1 FOR UPDATE`, id,
+	))
 	if err == pgx.ErrNoRows {
-		// First time this product is added — create a new entry.
-		err = s.db.QueryRow(ctx,
-			`INSERT INTO inventory (ean, quantity, expiry_date)
-			 VALUES ($1, 1, $2::date)
-			 RETURNING id`,
-			req.EAN, req.ExpiryDate,
-		).Scan(&id)
+		return nil, ErrInventoryEntryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ExpiryDate.Set {
+		_, err = tx.Exec(ctx,
+			`UPDATE inventory SET expiry_date = $2::date WHERE id = $1`,
+			id, req.ExpiryDate.Value,
+		)
 		if err != nil {
-			return nil, false, err
+			return nil, err
+		}
+	}
+	if req.LowStockThreshold != nil {
+		_, err = tx.Exec(ctx,
+			`UPDATE inventory SET low_stock_threshold = $2 WHERE ean = $1`,
+			l.ean, *req.LowStockThreshold,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if req.Amount != nil {
+		if err := setLotAmount(ctx, tx, l, *req.Amount, req.Unit); err != nil {
+			return nil, err
 		}
-		entry, err := s.getByID(ctx, id)
-		return entry, true, err
 	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.getByID(ctx, id)
+}
+
+// setLotAmount records that amount (in unit, nil: the product's unit) is
+// left of lot l; the package count follows from the package size.
+func setLotAmount(ctx context.Context, tx pgx.Tx, l lot, amount float64, unit *model.Unit) error {
+	productUnit, _, err := productMeasure(ctx, tx, l.ean)
 	if err != nil {
-		return nil, false, err
+		return err
+	}
+	amount, err = toProductUnit(amount, unit, productUnit)
+	if err != nil {
+		return err
+	}
+	n := max(int(math.Ceil(amount/l.size-amountEpsilon)), 1)
+	delta, amountDelta := n-l.quantity, amount-l.amount
+	if delta == 0 && math.Abs(amountDelta) < amountEpsilon {
+		return nil
+	}
+
+	if err := changeLot(ctx, tx, l, delta, amountDelta); err != nil {
+		return err
 	}
+	opID, err := newOperationID(ctx, tx)
+	if err != nil {
+		return err
+	}
+	return recordEvent(ctx, tx, stockEvent{
+		operationID: opID,
+		lot:         l,
+		delta:       delta,
+		amountDelta: amountDelta,
+		source:      model.EventSet,
+	})
+}
 
-	// Product already in inventory — increment quantity.
-	_, err = s.db.Exec(ctx,
-		`UPDATE inventory SET quantity = quantity + 1 WHERE id = $1`, id,
+// Move transfers req.Quantity units of ean from one location to another in a
+// single transaction. Units are taken from the source lots that are used
+// first and keep their expiry and opened date at the destination. Each
+// affected lot is recorded in the ledger.
+// Returns all lots of ean after the move.
+func (s *InventoryService) Move(
+	ctx context.Context, ean string, req model.MoveRequest,
+) ([]model.InventoryEntry, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	ean, err = canonicalEAN(ctx, tx, ean)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLocation(ctx, tx, req.FromLocationID); err != nil {
+		return nil, err
+	}
+	if err := checkLocation(ctx, tx, req.ToLocationID); err != nil {
+		return nil, err
+	}
+
+	lots, err := lockLots(ctx, tx, ean,
+		`location_id IS NOT DISTINCT FROM $2`, req.FromLocationID,
 	)
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
-	entry, err := s.getByID(ctx, id)
-	return entry, false, err
+	if len(lots) == 0 {
+		return nil, ErrInventoryEntryNotFound
+	}
+	available := 0
+	for _, l := range lots {
+		available += l.quantity
+	}
+	if available < req.Quantity {
+		return nil, ErrInsufficientStock
+	}
+
+	opID, err := newOperationID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	remaining := req.Quantity
+	for _, l := range lots {
+		if remaining == 0 {
+			break
+		}
+		n := min(remaining, l.quantity)
+		remaining -= n
+
+		// Add before taking, so a new destination lot still inherits the
+		// threshold when the source lot is emptied.
+		amount := l.amountOf(n)
+		to, _, err := addToLot(ctx, tx, ean, l.expiryDate, l.batch, req.ToLocationID, l.openedAt, n, amount)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := takeFromLot(ctx, tx, l, n); err != nil {
+			return nil, err
+		}
+
+		events := []stockEvent{
+			{operationID: opID, lot: l, delta: -n, amountDelta: -amount, source: model.EventMove},
+			{operationID: opID, lot: to, delta: n, amountDelta: amount, source: model.EventMove},
+		}
+		for _, ev := range events {
+			if err := recordEvent(ctx, tx, ev); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.listByEAN(ctx, ean)
 }
 
-// Remove decrements quantity by 1.
-// Returns nil when quantity reached 0 and the entry was deleted.
-func (s *InventoryService) Remove(ctx context.Context, ean string) (*model.InventoryEntry, error) {
-	var id, quantity int
-	err := s.db.QueryRow(ctx,
-		`SELECT id, quantity FROM inventory WHERE ean = $1`, ean,
-	).Scan(&id, &quantity)
+// Open marks one unit of lot id as opened today. A lot with more units is
+// split: the unit is moved to a lot of opened units (a partly used package
+// is the one opened), which is used before the unopened units. The split is
+// recorded in the ledger and can be undone.
+// Returns the lot holding the opened unit. Fails with ErrAlreadyOpened when
+// the lot holds opened units already.
+func (s *InventoryService) Open(ctx context.Context, id int) (*model.InventoryEntry, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	l, err := scanLot(tx.QueryRow(ctx,
+		`SELECT `+lotColumns+` FROM inventory WHERE id = $1 FOR UPDATE`, id,
+	))
 	if err == pgx.ErrNoRows {
 		return nil, ErrInventoryEntryNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
+	if l.openedAt != nil {
+		return nil, ErrAlreadyOpened
+	}
 
-	if quantity == 1 {
-		_, err = s.db.Exec(ctx, `DELETE FROM inventory WHERE id = $1`, id)
+	opID, err := newOperationID(ctx, tx)
+	if err != nil {
 		return nil, err
 	}
+	today := time.Now().Format("2006-01-02")
+	amount := l.amount - l.amountOf(l.quantity-1)
 
-	_, err = s.db.Exec(ctx,
-		`UPDATE inventory SET quantity = quantity - 1 WHERE id = $1`, id,
-	)
+	// Add before taking, so the opened lot inherits the threshold.
+	opened, _, err := addToLot(ctx, tx, l.ean, l.expiryDate, l.batch, l.locationID, &today, 1, amount)
 	if err != nil {
 		return nil, err
 	}
-	return s.getByID(ctx, id)
+	if err := changeLot(ctx, tx, l, -1, -amount); err != nil {
+		return nil, err
+	}
+	events := []stockEvent{
+		{operationID: opID, lot: l, delta: -1, amountDelta: -amount, source: model.EventOpen},
+		{operationID: opID, lot: opened, delta: 1, amountDelta: amount, source: model.EventOpen},
+	}
+	for _, ev := range events {
+		if err := recordEvent(ctx, tx, ev); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.getByID(ctx, opened.id)
+}
+
+// takeFIFO removes n units from lots, which must be locked and in FIFO
+// order, and records one ledger event per lot based on tmpl. Fails with
+// ErrInsufficientStock when the lots hold fewer than n units.
+// Returns the last lot taken from and whether it was emptied.
+func takeFIFO(
+	ctx context.Context, tx pgx.Tx, lots []lot, n int, tmpl stockEvent,
+) (lot, bool, error) {
+	available := 0
+	for _, l := range lots {
+		available += l.quantity
+	}
+	if available < n {
+		return lot{}, false, ErrInsufficientStock
+	}
+
+	var last lot
+	emptied := false
+	for _, l := range lots {
+		if n == 0 {
+			break
+		}
+		take := min(n, l.quantity)
+		n -= take
+
+		amount, err := takeFromLot(ctx, tx, l, take)
+		if err != nil {
+			return lot{}, false, err
+		}
+		ev := tmpl
+		ev.lot = l
+		ev.delta = -take
+		ev.amountDelta = -amount
+		if err := recordEvent(ctx, tx, ev); err != nil {
+			return lot{}, false, err
+		}
+		last, emptied = l, take == l.quantity
+	}
+	return last, emptied, nil
+}
+
+// takeAmountFIFO is takeFIFO for an amount in the product's unit; the last
+// lot taken from may be left with a partly used package. Fails with
+// ErrInsufficientStock when the lots hold less than amount.
+func takeAmountFIFO(
+	ctx context.Context, tx pgx.Tx, lots []lot, amount float64, tmpl stockEvent,
+) (lot, bool, error) {
+	available := 0.0
+	for _, l := range lots {
+		available += l.amount
+	}
+	if available < amount-amountEpsilon {
+		return lot{}, false, ErrInsufficientStock
+	}
+
+	var last lot
+	emptied := false
+	for _, l := range lots {
+		if amount < amountEpsilon {
+			break
+		}
+		take := min(amount, l.amount)
+		amount -= take
+
+		n, err := takeAmountFromLot(ctx, tx, l, take)
+		if err != nil {
+			return lot{}, false, err
+		}
+		ev := tmpl
+		ev.lot = l
+		ev.delta = -n
+		ev.amountDelta = -take
+		if err := recordEvent(ctx, tx, ev); err != nil {
+			return lot{}, false, err
+		}
+		last, emptied = l, l.amount-take < amountEpsilon
+	}
+	return last, emptied, nil
+}
+
+// unitsOrOne returns *q, or 1 when the optional quantity was not given.
+func unitsOrOne(q *int) int {
+	if q == nil {
+		return 1
+	}
+	return *q
+}
+
+// listByEAN returns all lots of ean in FIFO order.
+func (s *InventoryService) listByEAN(ctx context.Context, ean string) ([]model.InventoryEntry, error) {
+	return s.query(ctx, entrySelect+`
+		WHERE i.ean = $1
+		ORDER BY i.expiry_date NULLS LAST, i.id`, ean,
+	)
 }
 
 func (s *InventoryService) getByID(ctx context.Context, id int) (*model.InventoryEntry, error) {
-	var e model.InventoryEntry
-	err := s.db.QueryRow(ctx, `
-		SELECT i.id, i.quantity,
-		       TO_CHAR(i.expiry_date, 'YYYY-MM-DD'),
-		       i.low_stock_threshold,
-		       p.ean, p.name, p.category, p.image_url, p.resolved
-		FROM inventory i
-		JOIN products p ON p.ean = i.ean
+	e, err := scanEntry(s.db.QueryRow(ctx, entrySelect+`
 		WHERE i.id = $1`, id,
-	).Scan(
-		&e.ID, &e.Quantity, &e.ExpiryDate, &e.LowStockThreshold,
-		&e.Product.EAN, &e.Product.Name, &e.Product.Category, &e.Product.ImageURL, &e.Product.Resolved,
+	))
+	if err != nil {
+		return nil, err
+	}
+	entries := []model.InventoryEntry{*e}
+	if err := s.addForecast(ctx, entries); err != nil {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// query runs a SELECT built from entrySelect and decodes all rows.
+func (s *InventoryService) query(ctx context.Context, sql string, args ...any) ([]model.InventoryEntry, error) {
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.InventoryEntry{}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.addForecast(ctx, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// addForecast fills in the product's consumption rate and projected
+// depletion date on entries with consumption history.
+func (s *InventoryService) addForecast(ctx context.Context, entries []model.InventoryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	eans := make([]string, 0, len(entries))
+	for _, e := range entries {
+		eans = append(eans, e.Product.EAN)
+	}
+	rates, err := consumptionRates(ctx, s.db, eans)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		c, ok := rates[entries[i].Product.EAN]
+		if !ok {
+			continue
+		}
+		rate, date := c.roundedRate(), c.depletionDate()
+		entries[i].ConsumptionRate = &rate
+		entries[i].DepletionDate = &date
+	}
+	return nil
+}
+
+// scanEntry decodes one row selected with entrySelect.
+func scanEntry(row pgx.Row) (*model.InventoryEntry, error) {
+	var (
+		e            model.InventoryEntry
+		locationID   *int
+		locationName *string
 	)
+	dest := []any{
+		&e.ID, &e.Quantity, &e.Amount, &e.ExpiryDate, &e.Batch, &e.LowStockThreshold,
+		&e.OpenedAt, &e.OpenedUseBy,
+	}
+	dest = append(dest, productFields(&e.Product)...)
+	dest = append(dest, &locationID, &locationName)
+	err := row.Scan(dest...)
 	if err != nil {
 		return nil, err
 	}
+	if locationID != nil {
+		e.Location = &model.Location{ID: *locationID, Name: *locationName}
+	}
 	return &e, nil
 }
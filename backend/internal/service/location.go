@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"foodinventory/internal/model"
+)
+
+// Sentinel errors mapped to HTTP status codes in the handler layer.
+var (
+	ErrLocationNotFound = errors.New("location not found")
+	ErrLocationExists   = errors.New("location name already exists")
+	ErrLocationInUse    = errors.New("location still holds inventory")
+)
+
+// PostgreSQL error codes inspected by the services.
+const (
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+)
+
+// querier is implemented by both *pgxpool.Pool and pgx.Tx, so helpers can be
+// shared between plain queries and transactions.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// LocationService manages storage locations.
+type LocationService struct {
+	db *pgxpool.Pool
+}
+
+func NewLocationService(db *pgxpool.Pool) *LocationService {
+	return &LocationService{db: db}
+}
+
+// List returns all locations ordered by name.
+func (s *LocationService) List(ctx context.Context) ([]model.Location, error) {
+	rows, err := s.db.Query(ctx, `SELECT id, name FROM locations ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := []model.Location{}
+	for rows.Next() {
+		var l model.Location
+		if err := rows.Scan(&l.ID, &l.Name); err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, rows.Err()
+}
+
+// Create adds a new location. Returns ErrLocationExists when the name is
+// already taken.
+func (s *LocationService) Create(ctx context.Context, name string) (*model.Location, error) {
+	l := model.Location{Name: name}
+	err := s.db.QueryRow(ctx,
+		`INSERT INTO locations (name) VALUES ($1) RETURNING id`, name,
+	).Scan(&l.ID)
+	if isPgError(err, pgUniqueViolation) {
+		return nil, ErrLocationExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Rename changes the name of a location.
+func (s *LocationService) Rename(ctx context.Context, id int, name string) (*model.Location, error) {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE locations SET name = $2 WHERE id = $1`, id, name,
+	)
+	if isPgError(err, pgUniqueViolation) {
+		return nil, ErrLocationExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrLocationNotFound
+	}
+	return &model.Location{ID: id, Name: name}, nil
+}
+
+// Delete removes a location. Returns ErrLocationInUse while inventory lots
+// are still stored there.
+func (s *LocationService) Delete(ctx context.Context, id int) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM locations WHERE id = $1`, id)
+	if isPgError(err, pgForeignKeyViolation) {
+		return ErrLocationInUse
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLocationNotFound
+	}
+	return nil
+}
+
+// checkLocation returns ErrLocationNotFound when id is set but does not
+// reference an existing location. A nil id ("no location") is always valid.
+func checkLocation(ctx context.Context, q querier, id *int) error {
+	if id == nil {
+		return nil
+	}
+	var exists bool
+	err := q.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM locations WHERE id = $1)`, *id,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrLocationNotFound
+	}
+	return nil
+}
+
+// isPgError reports whether err is a PostgreSQL error with the given code.
+func isPgError(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/jackc/pgx/v5"
+
+	"foodinventory/internal/model"
+)
+
+// ErrIncompatibleUnit is mapped to HTTP 422 in the handler layer.
+var ErrIncompatibleUnit = errors.New("unit is not compatible with the product's unit")
+
+// amountEpsilon absorbs float rounding when comparing amounts, which are
+// stored with three decimals.
+const amountEpsilon = 0.0005
+
+// lot is one inventory row as needed by stock changes and ledger snapshots.
+// quantity counts packages and amount is what is left in the product's unit;
+// size is the product's package size (1 when unset). openedAt is set on
+// lots of opened units.
+type lot struct {
+	id         int
+	ean        string
+	quantity   int
+	amount     float64
+	size       float64
+	expiryDate *string
+	batch      *string
+	locationID *int
+	threshold  int
+	openedAt   *string
+}
+
+// lotColumns selects the fields of a lot from the inventory table.
+// Rows are decoded with scanLot.
+const lotColumns = `id, ean, quantity, amount,
+	(SELECT COALESCE(package_size, 1) FROM products WHERE products.ean = inventory.ean),
+	TO_CHAR(expiry_date, 'YYYY-MM-DD'), batch, location_id, low_stock_threshold,
+	TO_CHAR(opened_at, 'YYYY-MM-DD')`
+
+func scanLot(row pgx.Row) (lot, error) {
+	var l lot
+	err := row.Scan(
+		&l.id, &l.ean, &l.quantity, &l.amount, &l.size,
+		&l.expiryDate, &l.batch, &l.locationID, &l.threshold, &l.openedAt,
+	)
+	return l, err
+}
+
+// productMeasure returns the unit of ean and the amount in one package (1
+// when unset).
+func productMeasure(ctx context.Context, q querier, ean string) (model.Unit, float64, error) {
+	var (
+		unit model.Unit
+		size float64
+	)
+	err := q.QueryRow(ctx,
+		`SELECT unit, COALESCE(package_size, 1) FROM products WHERE ean = $1`, ean,
+	).Scan(&unit, &size)
+	if err == pgx.ErrNoRows {
+		return "", 0, ErrProductNotFound
+	}
+	return unit, size, err
+}
+
+// toProductUnit converts amount given in unit (nil: already in the product's
+// unit) to the product's unit. Fails with ErrIncompatibleUnit.
+func toProductUnit(amount float64, unit *model.Unit, productUnit model.Unit) (float64, error) {
+	if unit == nil {
+		return amount, nil
+	}
+	converted, ok := unit.Convert(amount, productUnit)
+	if !ok {
+		return 0, ErrIncompatibleUnit
+	}
+	return converted, nil
+}
+
+// lockLots returns the lots of ean matching cond in FIFO order (opened lots
+// first, then earliest expiry first, lots without an expiry date last) and
+// locks them until tx ends. cond is an SQL condition whose placeholders start
+// at $2.
+func lockLots(ctx context.Context, tx pgx.Tx, ean, cond string, args ...any) ([]lot, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT `+lotColumns+`
+		 FROM inventory
+		 WHERE ean = $1 AND (`+cond+`)
+		 ORDER BY opened_at NULLS LAST, expiry_date NULLS LAST, id
+		 FOR UPDATE`,
+		append([]any{ean}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []lot
+	for rows.Next() {
+		l, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	return lots, rows.Err()
+}
+
+// addToLot adds n packages holding amount to the lot of ean matching
+// expiryDate, batch, locationID and openedAt (nil for unopened units),
+// creating the lot when none exists. A new lot inherits the low-stock
+// threshold of existing lots of the same product.
+// Returns the updated lot and whether it was created.
+func addToLot(
+	ctx context.Context, tx pgx.Tx, ean string, expiryDate, batch *string,
+	locationID *int, openedAt *string, n int, amount float64,
+) (lot, bool, error) {
+	l, err := scanLot(tx.QueryRow(ctx,
+		`UPDATE inventory SET quantity = quantity + $4, amount = amount + $5
+		 WHERE id = (
+		     SELECT id FROM inventory
+		     WHERE ean = $1
+		       AND expiry_date IS NOT DISTINCT FROM $2::date
+		       AND location_id IS NOT DISTINCT FROM $3
+		       AND opened_at IS NOT DISTINCT FROM $6::date
+		       AND batch IS NOT DISTINCT FROM $7
+		     ORDER BY id
+		     LIMIT 1)
+		 RETURNING `+lotColumns,
+		ean, expiryDate, locationID, n, amount, openedAt, batch,
+	))
+	if err == nil {
+		return l, false, nil
+	}
+	if err != pgx.ErrNoRows {
+		return lot{}, false, err
+	}
+
+	l, err = scanLot(tx.QueryRow(ctx,
+		`INSERT INTO inventory
+		     (ean, quantity, amount, expiry_date, batch, location_id, opened_at,
+		      low_stock_threshold)
+		 VALUES ($1, $4, $5, $2::date, $7, $3, $6::date,
+		         COALESCE((SELECT MAX(low_stock_threshold) FROM inventory WHERE ean = $1), 1))
+		 RETURNING `+lotColumns,
+		ean, expiryDate, locationID, n, amount, openedAt, batch,
+	))
+	if err != nil {
+		return lot{}, false, err
+	}
+	return l, true, nil
+}
+
+// amountOf returns the amount held by n of the lot's packages. A partly used
+// package is counted last, so it stays in the lot until the last package.
+func (l lot) amountOf(n int) float64 {
+	if n >= l.quantity {
+		return l.amount
+	}
+	return min(float64(n)*l.size, l.amount)
+}
+
+// takeFromLot removes n whole packages from l and returns the amount taken.
+func takeFromLot(ctx context.Context, tx pgx.Tx, l lot, n int) (float64, error) {
+	amount := l.amountOf(n)
+	return amount, changeLot(ctx, tx, l, -n, -amount)
+}
+
+// takeAmountFromLot removes amount (at most l.amount) from l and returns the
+// number of packages used up by it.
+func takeAmountFromLot(ctx context.Context, tx pgx.Tx, l lot, amount float64) (int, error) {
+	n := l.quantity
+	if left := l.amount - amount; left > amountEpsilon {
+		n = max(l.quantity-int(math.Ceil(left/l.size-amountEpsilon)), 0)
+	}
+	return n, changeLot(ctx, tx, l, -n, -amount)
+}
+
+// changeLot adds n packages and amount to l; negative values take them out.
+// The row is deleted when it is emptied (quantity and amount are enforced
+// > 0).
+func changeLot(ctx context.Context, tx pgx.Tx, l lot, n int, amount float64) error {
+	var err error
+	if l.quantity+n <= 0 || l.amount+amount < amountEpsilon {
+		_, err = tx.Exec(ctx, `DELETE FROM inventory WHERE id = $1`, l.id)
+	} else {
+		_, err = tx.Exec(ctx,
+			`UPDATE inventory SET quantity = quantity + $2, amount = amount + $3
+			 WHERE id = $1`, l.id, n, amount,
+		)
+	}
+	return err
+}
+
+// restoreLot puts n packages holding amount back into lot l. When the row
+// was deleted in the meantime it is recreated with its original ID, expiry
+// date, batch, location, opened date and threshold, so a restored lot is
+// indistinguishable from the original.
+func restoreLot(ctx context.Context, tx pgx.Tx, l lot, n int, amount float64) error {
+	tag, err := tx.Exec(ctx,
+		`UPDATE inventory SET quantity = quantity + $2, amount = amount + $3
+		 WHERE id = $1`, l.id, n, amount,
+	)
+	if err != nil || tag.RowsAffected() > 0 {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO inventory
+		     (id, ean, quantity, amount, expiry_date, batch, location_id, opened_at,
+		      low_stock_threshold)
+		 VALUES ($1, $2, $3, $4, $5::date, $6, $7, $8::date, $9)`,
+		l.id, l.ean, n, amount, l.expiryDate, l.batch, l.locationID, l.openedAt, l.threshold,
+	)
+	return err
+}
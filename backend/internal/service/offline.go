@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/jackc/pgx/v5"
+
+	"foodinventory/internal/gtin"
+	"foodinventory/internal/model"
+	"foodinventory/internal/offdump"
+)
+
+// OfflineImport summarizes an import into the offline product database.
+type OfflineImport struct {
+	Read     int   // products read from the export
+	Bad      int   // records of the export that could not be decoded
+	Skipped  int   // products of the countries without a valid code or a name
+	Imported int64 // products stored
+}
+
+// ImportOffline replaces the offline product database with the products of
+// dump that are sold in any of countries (Open Food Facts country tags, see
+// offdump.CountryTag). Barcodes are stored under their canonical key, and
+// records that cannot be decoded are skipped. The export is streamed into
+// the database, and the table is replaced in one transaction, so lookups see
+// either the old or the new products.
+func (s *ProductService) ImportOffline(
+	ctx context.Context, dump *offdump.Reader, countries []string,
+) (*OfflineImport, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`CREATE TEMP TABLE offline_import (
+		     ean TEXT, name TEXT, category TEXT, image_url TEXT
+		 ) ON COMMIT DROP`,
+	); err != nil {
+		return nil, err
+	}
+
+	var stats OfflineImport
+	next := func() ([]any, error) {
+		for {
+			p, err := dump.Read()
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+			var recErr *offdump.RecordError
+			if errors.As(err, &recErr) {
+				stats.Bad++
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			stats.Read++
+			if !p.In(countries) {
+				continue
+			}
+			key, err := gtin.Normalize(p.Code)
+			if err != nil || p.Name == "" {
+				stats.Skipped++
+				continue
+			}
+			return []any{key, p.Name, nullIfEmpty(p.Category), nullIfEmpty(p.ImageURL)}, nil
+		}
+	}
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"offline_import"},
+		[]string{"ean", "name", "category", "image_url"},
+		pgx.CopyFromFunc(next),
+	); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM offline_products`); err != nil {
+		return nil, err
+	}
+	// The exports contain a few barcodes more than once; keep one of them.
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO offline_products (ean, name, category, image_url)
+		 SELECT DISTINCT ON (ean) ean, name, category, image_url
+		 FROM offline_import ORDER BY ean`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	stats.Imported = tag.RowsAffected()
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// lookupOffline returns the product from the offline product database, or
+// nil when it is not there.
+func (s *ProductService) lookupOffline(ctx context.Context, ean string) (*model.Product, error) {
+	p := &model.Product{
+		EAN: ean, Unit: model.UnitPieces,
+		Aliases: []string{}, ContainedIn: []string{},
+	}
+	err := s.db.QueryRow(ctx,
+		`SELECT name, category, image_url FROM offline_products WHERE ean = $1`, ean,
+	).Scan(&p.Name, &p.Category, &p.ImageURL)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
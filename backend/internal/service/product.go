@@ -2,60 +2,183 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/sync/singleflight"
 
+	"foodinventory/internal/gtin"
+	"foodinventory/internal/lookup"
 	"foodinventory/internal/model"
 )
 
-const openFoodFactsURL = "https://world.openfoodfacts.org/api/v2/product/%s"
+// ErrFetchTimeout is returned by GetOrFetch when the product lookup exceeded
+// the configured timeout. The caller may still add the product to inventory
+// using a stub row; the next scan will retry the lookup.
+var ErrFetchTimeout = errors.New("product lookup timed out")
 
-// ErrFetchTimeout is returned by GetOrFetch when the Open Food Facts request
-// exceeded the configured timeout. The caller may still add the product to
-// inventory using a stub row; the next scan will retry the lookup.
-var ErrFetchTimeout = errors.New("open food facts request timed out")
+// ErrLookupFailed is returned by GetOrFetch when a provider failed, e.g.
+// because the source is down or answered with an error. Like
+// ErrFetchTimeout, the caller may continue with a stub row.
+var ErrLookupFailed = errors.New("product lookup failed")
+
+// ErrProductNotFound is returned when an operation targets an EAN that has
+// no products row.
+var ErrProductNotFound = errors.New("product not found")
+
+// productColumns selects a model.Product from the products table aliased
+// as p. Scan it into the destinations returned by productFields.
+const productColumns = `p.ean, p.name, p.category, p.image_url, p.resolved,
+	p.min_quantity, p.target_quantity, p.unit, p.package_size, p.low_stock_amount,
+	p.days_after_opening,
+	ARRAY(SELECT a.alias FROM product_aliases a WHERE a.ean = p.ean ORDER BY a.alias),
+	p.group_id, p.contains_ean, p.contains_quantity,
+	ARRAY(SELECT c.ean FROM products c WHERE c.contains_ean = p.ean ORDER BY c.ean),
+	p.lookup_status, p.looked_up_at`
+
+// productFields returns the scan destinations matching productColumns.
+func productFields(p *model.Product) []any {
+	return []any{
+		&p.EAN, &p.Name, &p.Category, &p.ImageURL, &p.Resolved,
+		&p.MinQuantity, &p.TargetQuantity, &p.Unit, &p.PackageSize, &p.LowStockAmount,
+		&p.DaysAfterOpening, &p.Aliases, &p.GroupID,
+		&p.ContainsEAN, &p.ContainsQuantity, &p.ContainedIn,
+		&p.LookupStatus, &p.LookedUpAt,
+	}
+}
 
 // ProductService resolves EAN codes to product metadata,
 // caching results in the local products table.
 type ProductService struct {
-	db      *pgxpool.Pool
-	timeout time.Duration
+	db              *pgxpool.Pool
+	lookup          lookup.ProductLookup
+	notFoundTTL     time.Duration
+	variableMeasure []gtin.MeasureRule
+	fetches         singleflight.Group
 }
 
-func NewProductService(db *pgxpool.Pool, timeout time.Duration) *ProductService {
-	return &ProductService{db: db, timeout: timeout}
+// NewProductService creates the service. Unknown products are resolved with
+// lookup, usually a lookup.Chain; that no provider knows a product is cached
+// for notFoundTTL. variableMeasure decodes in-store codes that embed a weight
+// or price; see gtin.MeasureRule.
+func NewProductService(
+	db *pgxpool.Pool, lookup lookup.ProductLookup, notFoundTTL time.Duration,
+	variableMeasure []gtin.MeasureRule,
+) *ProductService {
+	return &ProductService{
+		db:              db,
+		lookup:          lookup,
+		notFoundTTL:     notFoundTTL,
+		variableMeasure: variableMeasure,
+	}
 }
 
-// GetOrFetch returns a cached product or fetches it from Open Food Facts.
-// Returns nil, nil when the EAN is unknown in the external API.
-// Returns nil, ErrFetchTimeout when the external request exceeded the timeout.
+// splitMeasure maps a variable-measure code, such as a scale label, to the
+// base item code shared by all labels of the item and the weight in grams
+// embedded in it (nil when it embeds a price). Other codes are returned
+// unchanged.
+func (s *ProductService) splitMeasure(ean string) (string, *float64) {
+	m, ok := gtin.DecodeMeasure(ean, s.variableMeasure)
+	if !ok {
+		return ean, nil
+	}
+	return m.Item, m.Weight
+}
+
+// weighByDefault switches a product to be measured in grams, with the weight
+// of its first scale label as the package size. Only products that still
+// have the default measure (pcs without package size) and no stock are
+// changed.
+func (s *ProductService) weighByDefault(ctx context.Context, ean string, grams float64) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE products SET unit = $2, package_size = $3
+		 WHERE ean = $1 AND unit = $4 AND package_size IS NULL
+		   AND NOT EXISTS (SELECT 1 FROM inventory WHERE ean = $1)`,
+		ean, model.UnitGrams, grams, model.UnitPieces,
+	)
+	return err
+}
+
+// GetOrFetch returns a cached product, or looks it up in the offline product
+// database and then with the configured providers. A product from the
+// offline database is cached without any network request, even if the
+// providers did not know it before.
+// Returns nil, nil when no provider knows the EAN; this result is cached for
+// the not-found TTL, so repeated calls return at once.
+// Returns nil, ErrFetchTimeout when the external request exceeded the timeout,
+// nil, lookup.ErrRateLimited when the lookup was not sent because of the
+// rate limit, and an error wrapping ErrLookupFailed when a provider failed
+// otherwise, including while its circuit breaker is open. Failed lookups are
+// not cached. Unsuccessful lookups leave a stub row with the outcome.
+// Concurrent calls for the same EAN share one lookup.
+// Codes from the restricted-circulation range are never looked up.
 func (s *ProductService) GetOrFetch(ctx context.Context, ean string) (*model.Product, error) {
-	p, err := s.getFromDB(ctx, ean)
+	p, knownUnknown, err := s.getFromDB(ctx, ean)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil || gtin.Restricted(ean) {
+		return p, nil
+	}
+
+	p, err = s.lookupOffline(ctx, ean)
 	if err != nil {
 		return nil, err
 	}
 	if p != nil {
+		if err := s.upsert(ctx, p); err != nil {
+			return nil, err
+		}
 		return p, nil
 	}
+	if knownUnknown {
+		return nil, nil
+	}
 
-	fetchCtx, cancel := context.WithTimeout(ctx, s.timeout)
-	defer cancel()
+	return s.sharedFetch(ctx, ean)
+}
+
+// sharedFetch runs fetch for ean, sharing it with concurrent callers for the
+// same EAN. The shared lookup outlives a caller that gives up, bounded by the
+// providers' timeouts; the caller itself returns as soon as ctx is done.
+func (s *ProductService) sharedFetch(ctx context.Context, ean string) (*model.Product, error) {
+	ch := s.fetches.DoChan(ean, func() (any, error) {
+		return s.fetch(context.WithoutCancel(ctx), ean)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*model.Product), nil
+	}
+}
 
-	p, err = fetchFromOpenFoodFacts(fetchCtx, ean)
+// fetch looks ean up with the providers and caches the outcome.
+func (s *ProductService) fetch(ctx context.Context, ean string) (*model.Product, error) {
+	p, err := s.lookup.Lookup(ctx, ean)
+	if errors.Is(err, lookup.ErrRateLimited) {
+		if err := s.recordLookup(ctx, ean, model.LookupRateLimited); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		if err := s.recordLookup(ctx, ean, model.LookupFailed); err != nil {
+			return nil, err
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
 			return nil, ErrFetchTimeout
 		}
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrLookupFailed, err)
 	}
 	if p == nil {
-		return nil, nil
+		return nil, s.recordLookup(ctx, ean, model.LookupNotFound)
 	}
 
 	if err := s.upsert(ctx, p); err != nil {
@@ -64,6 +187,47 @@ func (s *ProductService) GetOrFetch(ctx context.Context, ean string) (*model.Pro
 	return p, nil
 }
 
+// Create adds a resolved product without a barcode under the next internal
+// code (see gtin.Internal). Codes that are already taken, e.g. by a scanned
+// in-store label, are skipped.
+func (s *ProductService) Create(ctx context.Context, req model.CreateProductRequest) (*model.Product, error) {
+	unit := model.UnitPieces
+	if req.Unit != nil {
+		unit = *req.Unit
+	}
+	unit, factor, _ := unit.Base()
+	var packageSize *float64
+	if req.PackageSize != nil {
+		v := *req.PackageSize * factor
+		packageSize = &v
+	}
+
+	for {
+		var n int64
+		err := s.db.QueryRow(ctx, `SELECT nextval('internal_product_code_seq')`).Scan(&n)
+		if err != nil {
+			return nil, err
+		}
+
+		var p model.Product
+		err = s.db.QueryRow(ctx,
+			`INSERT INTO products AS p
+			   (ean, name, category, resolved, unit, package_size, days_after_opening)
+			 VALUES ($1, $2, $3, TRUE, $4, $5, $6)
+			 ON CONFLICT (ean) DO NOTHING
+			 RETURNING `+productColumns,
+			gtin.Internal(n), req.Name, req.Category, unit, packageSize, req.DaysAfterOpening,
+		).Scan(productFields(&p)...)
+		if err == pgx.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &p, nil
+	}
+}
+
 // InsertStub inserts a placeholder products row for the given EAN with
 // resolved = FALSE and the EAN itself as the name. It is a no-op if any row
 // for that EAN already exists (resolved or stub), preserving a previously
@@ -78,15 +242,218 @@ func (s *ProductService) InsertStub(ctx context.Context, ean string) error {
 	return err
 }
 
-// getFromDB returns the cached product for ean, or nil if not found / not yet
-// resolved (stub row inserted after a previous timeout).
-func (s *ProductService) getFromDB(ctx context.Context, ean string) (*model.Product, error) {
+// Ensure makes sure a products row exists for ean, resolving it from the
+// cache or the lookup providers. When the EAN is unknown, or the lookup timed
+// out, failed or was rate limited, a stub row is inserted, so the product can
+// be referenced in any case.
+func (s *ProductService) Ensure(ctx context.Context, ean string) error {
+	product, err := s.GetOrFetch(ctx, ean)
+	if err != nil && !errors.Is(err, ErrFetchTimeout) && !errors.Is(err, ErrLookupFailed) &&
+		!errors.Is(err, lookup.ErrRateLimited) {
+		return err
+	}
+	if product == nil {
+		// EAN not found in external API, fetch timed out, failed or rate limited —
+		// insert a stub row using the EAN as the name with resolved = false.
+		return s.InsertStub(ctx, ean)
+	}
+	return nil
+}
+
+// Watch puts the product on the watch list with the given product-level
+// minimum quantity. The product is resolved first, so products that are not
+// in stock can be watched too; aliases are followed.
+func (s *ProductService) Watch(ctx context.Context, ean string, minQuantity int) (*model.Product, error) {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Ensure(ctx, ean); err != nil {
+		return nil, err
+	}
+	var p model.Product
+	err = s.db.QueryRow(ctx,
+		`UPDATE products AS p SET min_quantity = $2 WHERE ean = $1
+		 RETURNING `+productColumns,
+		ean, minQuantity,
+	).Scan(productFields(&p)...)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetTargetQuantity sets the quantity the shopping list tops the product up
+// to; nil restores the default (one more than the low-stock threshold).
+// Aliases are followed. Returns ErrProductNotFound when the EAN is unknown.
+func (s *ProductService) SetTargetQuantity(ctx context.Context, ean string, target *int) (*model.Product, error) {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, err
+	}
+	var p model.Product
+	err = s.db.QueryRow(ctx,
+		`UPDATE products AS p SET target_quantity = $2 WHERE ean = $1
+		 RETURNING `+productColumns,
+		ean, target,
+	).Scan(productFields(&p)...)
+	if err == pgx.ErrNoRows {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetMeasure sets the unit, package size and amount-based low-stock
+// threshold of a product. req.PackageSize and req.LowStockAmount are given in
+// req.Unit and stored in its base unit. The amounts of existing lots are
+// rescaled to the new package size, so partly used packages stay partly used.
+// Aliases are followed. Returns ErrProductNotFound when the EAN is unknown.
+func (s *ProductService) SetMeasure(ctx context.Context, ean string, req model.MeasureRequest) (*model.Product, error) {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, err
+	}
+
+	unit, factor, _ := req.Unit.Base()
+	var packageSize, lowStockAmount *float64
+	if req.PackageSize != nil {
+		v := *req.PackageSize * factor
+		packageSize = &v
+	}
+	if req.LowStockAmount != nil {
+		v := *req.LowStockAmount * factor
+		lowStockAmount = &v
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var oldSize float64
+	err = tx.QueryRow(ctx,
+		`SELECT COALESCE(package_size, 1) FROM products WHERE ean = $1 FOR UPDATE`, ean,
+	).Scan(&oldSize)
+	if err == pgx.ErrNoRows {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var p model.Product
+	err = tx.QueryRow(ctx,
+		`UPDATE products AS p
+		 SET unit = $2, package_size = $3, low_stock_amount = $4
+		 WHERE ean = $1
+		 RETURNING `+productColumns,
+		ean, unit, packageSize, lowStockAmount,
+	).Scan(productFields(&p)...)
+	if err != nil {
+		return nil, err
+	}
+	newSize := 1.0
+	if packageSize != nil {
+		newSize = *packageSize
+	}
+	if newSize != oldSize {
+		_, err = tx.Exec(ctx,
+			`UPDATE inventory SET amount = GREATEST(ROUND(amount * $2 / $3, 3), 0.001)
+			 WHERE ean = $1`,
+			ean, newSize, oldSize,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetDaysAfterOpening sets how many days the product stays good once opened;
+// nil clears it. Aliases are followed. Returns ErrProductNotFound when the
+// EAN is unknown.
+func (s *ProductService) SetDaysAfterOpening(ctx context.Context, ean string, days *int) (*model.Product, error) {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return nil, err
+	}
+	var p model.Product
+	err = s.db.QueryRow(ctx,
+		`UPDATE products AS p SET days_after_opening = $2 WHERE ean = $1
+		 RETURNING `+productColumns,
+		ean, days,
+	).Scan(productFields(&p)...)
+	if err == pgx.ErrNoRows {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Unwatch removes the product from the watch list; aliases are followed.
+// Returns ErrProductNotFound when the EAN is unknown.
+func (s *ProductService) Unwatch(ctx context.Context, ean string) error {
+	ean, err := canonicalEAN(ctx, s.db, ean)
+	if err != nil {
+		return err
+	}
+	tag, err := s.db.Exec(ctx,
+		`UPDATE products SET min_quantity = NULL WHERE ean = $1`, ean,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+// ListWatched returns all watched products with their total stock, ordered
+// by name.
+func (s *ProductService) ListWatched(ctx context.Context) ([]model.WatchedProduct, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+productColumns+`, COALESCE(SUM(i.quantity), 0)
+		FROM products p
+		LEFT JOIN inventory i ON i.ean = p.ean
+		WHERE p.min_quantity IS NOT NULL
+		GROUP BY p.ean
+		ORDER BY p.name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	watched := []model.WatchedProduct{}
+	for rows.Next() {
+		var w model.WatchedProduct
+		if err := rows.Scan(append(productFields(&w.Product), &w.Quantity)...); err != nil {
+			return nil, err
+		}
+		watched = append(watched, w)
+	}
+	return watched, rows.Err()
+}
+
+// get returns the product row for ean, resolved or not.
+// Returns ErrProductNotFound when the EAN is unknown.
+func (s *ProductService) get(ctx context.Context, ean string) (*model.Product, error) {
 	var p model.Product
 	err := s.db.QueryRow(ctx,
-		`SELECT ean, name, category, image_url FROM products WHERE ean = $1 AND resolved = TRUE`, ean,
-	).Scan(&p.EAN, &p.Name, &p.Category, &p.ImageURL)
+		`SELECT `+productColumns+` FROM products p WHERE p.ean = $1`, ean,
+	).Scan(productFields(&p)...)
 	if err == pgx.ErrNoRows {
-		return nil, nil
+		return nil, ErrProductNotFound
 	}
 	if err != nil {
 		return nil, err
@@ -94,67 +461,96 @@ func (s *ProductService) getFromDB(ctx context.Context, ean string) (*model.Prod
 	return &p, nil
 }
 
+// getFromDB returns the cached product for ean, or nil if not found / not yet
+// resolved (stub row inserted after a previous timeout). knownUnknown
+// reports a stub whose last lookup found that no provider knows it, within
+// the not-found TTL.
+func (s *ProductService) getFromDB(
+	ctx context.Context, ean string,
+) (p *model.Product, knownUnknown bool, err error) {
+	p = &model.Product{}
+	err = s.db.QueryRow(ctx,
+		`SELECT `+productColumns+`,
+		        p.lookup_status = 'not_found' AND p.looked_up_at > now() - $2::interval
+		 FROM products p WHERE p.ean = $1`, ean, s.notFoundTTL,
+	).Scan(append(productFields(p), &knownUnknown)...)
+	if err == pgx.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !p.Resolved {
+		return nil, knownUnknown, nil
+	}
+	return p, false, nil
+}
+
+// upsert caches a product found by the providers. It replaces a stub row,
+// but not a product that was named manually while it was looked up.
 func (s *ProductService) upsert(ctx context.Context, p *model.Product) error {
 	_, err := s.db.Exec(ctx,
-		`INSERT INTO products (ean, name, category, image_url, resolved)
-		 VALUES ($1, $2, $3, $4, TRUE)
+		`INSERT INTO products AS p (ean, name, category, image_url, resolved, lookup_status, looked_up_at)
+		 VALUES ($1, $2, $3, $4, TRUE, $5, now())
 		 ON CONFLICT (ean) DO UPDATE
-		   SET name = $2, category = $3, image_url = $4, resolved = TRUE`,
-		p.EAN, p.Name, p.Category, p.ImageURL,
+		   SET name = $2, category = $3, image_url = $4, resolved = TRUE,
+		       lookup_status = $5, looked_up_at = now()
+		   WHERE NOT p.resolved`,
+		p.EAN, p.Name, p.Category, p.ImageURL, model.LookupFound,
 	)
 	return err
 }
 
-// UpdateProduct sets a user-provided name and category on a product row and
-// marks it as resolved = TRUE. This allows manual naming of unknown products.
-func (s *ProductService) UpdateProduct(ctx context.Context, ean, name string, category *string) error {
+// recordLookup records an unsuccessful lookup of ean on its stub row,
+// inserting the stub if needed. Resolved products are left unchanged.
+func (s *ProductService) recordLookup(ctx context.Context, ean string, status model.LookupStatus) error {
 	_, err := s.db.Exec(ctx,
-		`UPDATE products SET name = $2, category = $3, resolved = TRUE WHERE ean = $1`,
-		ean, name, category,
+		`INSERT INTO products AS p (ean, name, resolved, lookup_status, looked_up_at)
+		 VALUES ($1, $1, FALSE, $2, now())
+		 ON CONFLICT (ean) DO UPDATE
+		   SET lookup_status = $2, looked_up_at = now()
+		   WHERE NOT p.resolved`,
+		ean, status,
 	)
 	return err
 }
 
-// offResponse maps the subset of the Open Food Facts API response we need.
-type offResponse struct {
-	Status  int `json:"status"`
-	Product struct {
-		ProductName        string   `json:"product_name"`
-		CategoriesTags     []string `json:"categories_tags"`
-		ImageFrontSmallURL string   `json:"image_front_small_url"`
-	} `json:"product"`
-}
-
-func fetchFromOpenFoodFacts(ctx context.Context, ean string) (*model.Product, error) {
-	url := fmt.Sprintf(openFoodFactsURL, ean)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// UpdateProduct sets a user-provided name and category on a product row and
+// marks it as resolved = TRUE. This allows manual naming of unknown products.
+// The update is recorded in the stock movement ledger with a zero delta.
+// Aliases are followed. Returns ErrProductNotFound when the EAN is unknown.
+func (s *ProductService) UpdateProduct(ctx context.Context, ean, name string, category *string) error {
+	ean, err := canonicalEAN(ctx, s.db, ean)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	req.Header.Set("User-Agent", "FoodInventory/1.0 (home warehouse tool)")
-
-	resp, err := http.DefaultClient.Do(req)
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer resp.Body.Close()
+	defer tx.Rollback(ctx)
 
-	var off offResponse
-	if err := json.NewDecoder(resp.Body).Decode(&off); err != nil {
-		return nil, err
+	tag, err := tx.Exec(ctx,
+		`UPDATE products SET name = $2, category = $3, resolved = TRUE WHERE ean = $1`,
+		ean, name, category,
+	)
+	if err != nil {
+		return err
 	}
-	if off.Status == 0 {
-		return nil, nil // product not found in Open Food Facts
+	if tag.RowsAffected() == 0 {
+		return ErrProductNotFound
 	}
-
-	p := &model.Product{EAN: ean, Name: off.Product.ProductName}
-	if len(off.Product.CategoriesTags) > 0 {
-		cat := off.Product.CategoriesTags[0]
-		p.Category = &cat
+	opID, err := newOperationID(ctx, tx)
+	if err != nil {
+		return err
 	}
-	if off.Product.ImageFrontSmallURL != "" {
-		img := off.Product.ImageFrontSmallURL
-		p.ImageURL = &img
+	err = recordEvent(ctx, tx, stockEvent{
+		operationID: opID,
+		lot:         lot{ean: ean},
+		source:      model.EventProductUpdate,
+	})
+	if err != nil {
+		return err
 	}
-	return p, nil
+	return tx.Commit(ctx)
 }
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"foodinventory/internal/model"
+)
+
+// wasteReasons lists the removal reasons that count as food waste.
+var wasteReasons = []string{string(model.ReasonExpired), string(model.ReasonSpoiled)}
+
+// wasteEvents selects the discarded units of every waste removal in the
+// period [$2, $3), skipping removals that were undone. Units are counted by
+// amount, in packages of the product's package size, so the part of an
+// opened package that was thrown away counts as that fraction of a package.
+// Removals recorded under an alias count for the alias's product. The
+// aggregation queries append their GROUP BY to it and select wasteCounts.
+const wasteEvents = `
+	WITH waste AS (
+		SELECT COALESCE(a.ean, e.ean) AS ean, COALESCE(p.name, e.ean) AS name,
+		       p.category, TO_CHAR(e.occurred_at, 'YYYY-MM') AS month,
+		       -e.amount_delta / COALESCE(p.package_size, 1) AS units, e.reason
+		FROM inventory_events e
+		LEFT JOIN product_aliases a ON a.alias = e.ean
+		LEFT JOIN products p ON p.ean = COALESCE(a.ean, e.ean)
+		WHERE e.amount_delta < 0
+		  AND e.reason = ANY($1)
+		  AND ($2::timestamptz IS NULL OR e.occurred_at >= $2)
+		  AND ($3::timestamptz IS NULL OR e.occurred_at < $3)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM inventory_events u
+		      WHERE u.reverts_operation_id = e.operation_id))`
+
+// wasteCounts aggregates waste rows into the fields of model.WasteCount,
+// rounded to two decimals.
+const wasteCounts = `
+	ROUND(COALESCE(SUM(units), 0), 2)::float8,
+	ROUND(COALESCE(SUM(units) FILTER (WHERE reason = 'expired'), 0), 2)::float8,
+	ROUND(COALESCE(SUM(units) FILTER (WHERE reason = 'spoiled'), 0), 2)::float8`
+
+// ReportService computes reports from the stock movement ledger.
+type ReportService struct {
+	db *pgxpool.Pool
+}
+
+func NewReportService(db *pgxpool.Pool) *ReportService {
+	return &ReportService{db: db}
+}
+
+// Waste aggregates the units removed as expired or spoiled between from
+// (inclusive) and to (exclusive) by product, category and month. Nil bounds
+// are open. Months follow the database time zone.
+func (s *ReportService) Waste(ctx context.Context, from, to *time.Time) (*model.WasteReport, error) {
+	report := model.WasteReport{
+		From:       from,
+		To:         to,
+		ByProduct:  []model.ProductWaste{},
+		ByCategory: []model.CategoryWaste{},
+		ByMonth:    []model.MonthWaste{},
+	}
+	args := []any{wasteReasons, from, to}
+
+	err := s.db.QueryRow(ctx,
+		wasteEvents+` SELECT `+wasteCounts+` FROM waste`, args...,
+	).Scan(wasteFields(&report.Total)...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, wasteEvents+`
+		SELECT ean, name, category, `+wasteCounts+`
+		FROM waste
+		GROUP BY ean, name, category
+		ORDER BY 4 DESC, name`, args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var w model.ProductWaste
+		if err := rows.Scan(append([]any{&w.EAN, &w.ProductName, &w.Category},
+			wasteFields(&w.WasteCount)...)...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.ByProduct = append(report.ByProduct, w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(ctx, wasteEvents+`
+		SELECT category, `+wasteCounts+`
+		FROM waste
+		GROUP BY category
+		ORDER BY 2 DESC, category NULLS LAST`, args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var w model.CategoryWaste
+		if err := rows.Scan(append([]any{&w.Category},
+			wasteFields(&w.WasteCount)...)...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.ByCategory = append(report.ByCategory, w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(ctx, wasteEvents+`
+		SELECT month, `+wasteCounts+`
+		FROM waste
+		GROUP BY month
+		ORDER BY month`, args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var w model.MonthWaste
+		if err := rows.Scan(append([]any{&w.Month},
+			wasteFields(&w.WasteCount)...)...); err != nil {
+			return nil, err
+		}
+		report.ByMonth = append(report.ByMonth, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// wasteFields returns the scan destinations for the columns of wasteCounts.
+func wasteFields(c *model.WasteCount) []any {
+	return []any{&c.Units, &c.Expired, &c.Spoiled}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"foodinventory/internal/gtin"
+	"foodinventory/internal/lookup"
+)
+
+// Backoff of background stub lookups: the first retry of a product is due
+// after stubBackoffBase, and every failed attempt doubles the delay up to
+// stubBackoffMax.
+const (
+	stubBackoffBase = 5 * time.Minute
+	stubBackoffMax  = 7 * 24 * time.Hour
+)
+
+// stubBatchSize bounds how many due stubs one pass of the resolver picks up.
+const stubBatchSize = 100
+
+// StubResolver periodically looks up stub products again, so a product whose
+// lookup timed out once gets its name without being scanned again. It stops
+// retrying a product once it is resolved, either by a lookup or by being
+// named manually.
+type StubResolver struct {
+	db         *pgxpool.Pool
+	productSvc *ProductService
+	interval   time.Duration
+	spacing    time.Duration
+}
+
+// NewStubResolver creates a resolver that checks for due stubs every
+// interval and starts at most perMinute lookups per minute.
+func NewStubResolver(
+	db *pgxpool.Pool, productSvc *ProductService, interval time.Duration, perMinute int,
+) *StubResolver {
+	return &StubResolver{
+		db:         db,
+		productSvc: productSvc,
+		interval:   interval,
+		spacing:    time.Minute / time.Duration(perMinute),
+	}
+}
+
+// Run resolves due stubs until ctx is cancelled. Errors are logged and the
+// pass is retried after the next interval.
+func (r *StubResolver) Run(ctx context.Context) {
+	limiter := time.NewTicker(r.spacing)
+	defer limiter.Stop()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.resolveDue(ctx, limiter.C); err != nil && ctx.Err() == nil {
+			log.Printf("stub resolver: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolveDue looks up every stub whose next attempt is due, waiting for the
+// limiter before each lookup.
+func (r *StubResolver) resolveDue(ctx context.Context, limiter <-chan time.Time) error {
+	rows, err := r.db.Query(ctx,
+		`SELECT ean FROM products
+		 WHERE NOT resolved AND (next_lookup_at IS NULL OR next_lookup_at <= now())
+		 ORDER BY next_lookup_at NULLS FIRST, ean
+		 LIMIT $1`, stubBatchSize,
+	)
+	if err != nil {
+		return err
+	}
+	var eans []string
+	for rows.Next() {
+		var ean string
+		if err := rows.Scan(&ean); err != nil {
+			rows.Close()
+			return err
+		}
+		eans = append(eans, ean)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, ean := range eans {
+		if gtin.Restricted(ean) {
+			// In-store codes are never looked up; stop considering them.
+			if _, err := r.db.Exec(ctx,
+				`UPDATE products SET next_lookup_at = 'infinity' WHERE ean = $1`, ean,
+			); err != nil {
+				return err
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-limiter:
+		}
+		err := r.productSvc.retryStub(ctx, ean)
+		if errors.Is(err, lookup.ErrRateLimited) || errors.Is(err, lookup.ErrCircuitOpen) {
+			// Scans take precedence, and a source that is down is not
+			// worth asking; continue with the next pass.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryStub looks the stub ean up again, first in the offline product
+// database, then with the providers through the lookup shared with scans of
+// the same EAN. A product found replaces the stub, unless the stub was named
+// manually in the meantime; otherwise the next attempt is scheduled with
+// exponential backoff. A lookup that was not sent because of the rate limit
+// or an open circuit breaker is not counted as an attempt and returns
+// lookup.ErrRateLimited or lookup.ErrCircuitOpen.
+func (s *ProductService) retryStub(ctx context.Context, ean string) error {
+	p, err := s.lookupOffline(ctx, ean)
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		return s.upsert(ctx, p)
+	}
+
+	p, err = s.sharedFetch(ctx, ean)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, lookup.ErrRateLimited) || errors.Is(err, lookup.ErrCircuitOpen) {
+		return err
+	}
+	if p != nil {
+		return nil
+	}
+	if err != nil && !errors.Is(err, ErrFetchTimeout) && !errors.Is(err, ErrLookupFailed) {
+		return err
+	}
+	// The outcome was recorded by the lookup; schedule the next attempt.
+	_, err = s.db.Exec(ctx,
+		`UPDATE products
+		 SET lookup_attempts = lookup_attempts + 1,
+		     next_lookup_at = now() + LEAST(
+		         $2::float8 * power(2, LEAST(lookup_attempts, 30)), $3::float8
+		     ) * interval '1 second'
+		 WHERE ean = $1 AND NOT resolved`,
+		ean, stubBackoffBase.Seconds(), stubBackoffMax.Seconds(),
+	)
+	return err
+}
@@ -20,8 +20,8 @@ func NewSettingsService(db *pgxpool.Pool) *SettingsService {
 func (s *SettingsService) Get(ctx context.Context) (*model.Settings, error) {
 	var settings model.Settings
 	err := s.db.QueryRow(ctx,
-		`SELECT expiry_warning_days FROM settings WHERE id = 1`,
-	).Scan(&settings.ExpiryWarningDays)
+		`SELECT expiry_warning_days, depletion_horizon_days FROM settings WHERE id = 1`,
+	).Scan(&settings.ExpiryWarningDays, &settings.DepletionHorizonDays)
 	if err != nil {
 		return nil, err
 	}
@@ -30,8 +30,9 @@ func (s *SettingsService) Get(ctx context.Context) (*model.Settings, error) {
 
 func (s *SettingsService) Update(ctx context.Context, in model.Settings) (*model.Settings, error) {
 	_, err := s.db.Exec(ctx,
-		`UPDATE settings SET expiry_warning_days = $1 WHERE id = 1`,
-		in.ExpiryWarningDays,
+		`UPDATE settings SET expiry_warning_days = $1, depletion_horizon_days = $2
+		 WHERE id = 1`,
+		in.ExpiryWarningDays, in.DepletionHorizonDays,
 	)
 	if err != nil {
 		return nil, err
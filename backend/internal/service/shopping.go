@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"foodinventory/internal/model"
+)
+
+// ErrShoppingItemNotFound is mapped to HTTP 404 in the handler layer.
+var ErrShoppingItemNotFound = errors.New("shopping list item not found")
+
+// shoppingItemSelect reads shopping list items; items that reference a
+// product show the product's current name. Rows are decoded with
+// scanShoppingItem.
+const shoppingItemSelect = `
+	SELECT s.id, s.ean, COALESCE(p.name, s.name, s.ean), s.quantity,
+	       s.checked, s.automatic, s.created_at
+	FROM shopping_list_items s
+	LEFT JOIN products p ON p.ean = s.ean`
+
+// ShoppingListService manages the shopping list. Besides manual items, the
+// list fills itself from the low-stock and out-of-stock conditions computed
+// by AlertService.
+type ShoppingListService struct {
+	db         *pgxpool.Pool
+	alertSvc   *AlertService
+	productSvc *ProductService
+}
+
+func NewShoppingListService(
+	db *pgxpool.Pool, alertSvc *AlertService, productSvc *ProductService,
+) *ShoppingListService {
+	return &ShoppingListService{db: db, alertSvc: alertSvc, productSvc: productSvc}
+}
+
+// List brings the automatic items up to date and returns the whole list,
+// open items first.
+func (s *ShoppingListService) List(ctx context.Context) ([]model.ShoppingListItem, error) {
+	if err := s.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, shoppingItemSelect+`
+		ORDER BY s.checked, 3, s.id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []model.ShoppingListItem{}
+	for rows.Next() {
+		item, err := scanShoppingItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// Add puts a manual item on the list. Items with an EAN are resolved like
+// inventory scans: aliases are followed and unknown products get a stub row.
+func (s *ShoppingListService) Add(
+	ctx context.Context, req model.AddShoppingListItemRequest,
+) (*model.ShoppingListItem, error) {
+	if req.EAN != nil {
+		ean, err := canonicalEAN(ctx, s.db, *req.EAN)
+		if err != nil {
+			return nil, err
+		}
+		req.EAN = &ean
+		if err := s.productSvc.Ensure(ctx, ean); err != nil {
+			return nil, err
+		}
+	}
+
+	var id int
+	err := s.db.QueryRow(ctx,
+		`INSERT INTO shopping_list_items (ean, name, quantity)
+		 VALUES ($1, $2, $3)
+		 RETURNING id`,
+		req.EAN, req.Name, unitsOrOne(req.Quantity),
+	).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return s.getByID(ctx, id)
+}
+
+// Update checks off (or re-opens) an item and/or changes its quantity.
+// Editing an automatic item turns it into a manual one, so the automatic
+// sync no longer overrides the user's choice.
+func (s *ShoppingListService) Update(
+	ctx context.Context, id int, req model.UpdateShoppingListItemRequest,
+) (*model.ShoppingListItem, error) {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE shopping_list_items
+		 SET checked   = COALESCE($2, checked),
+		     quantity  = COALESCE($3, quantity),
+		     automatic = automatic AND $3::int IS NULL
+		 WHERE id = $1`,
+		id, req.Checked, req.Quantity,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrShoppingItemNotFound
+	}
+	return s.getByID(ctx, id)
+}
+
+// Delete removes one item from the list.
+func (s *ShoppingListService) Delete(ctx context.Context, id int) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM shopping_list_items WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrShoppingItemNotFound
+	}
+	return nil
+}
+
+// Clear removes all checked items, or every item when all is true.
+func (s *ShoppingListService) Clear(ctx context.Context, all bool) error {
+	_, err := s.db.Exec(ctx,
+		`DELETE FROM shopping_list_items WHERE checked OR $1`, all,
+	)
+	return err
+}
+
+// sync reconciles the automatic items with the current stock levels in one
+// transaction: every product at or below its threshold gets an open item for
+// the units missing to its target quantity, and open automatic items of
+// products that recovered are dropped. A low product group is restocked with
+// its most recently added member. Products that already have any other item
+// on the list (manual, or checked off but not yet scanned in) are left alone;
+// automatic items are removed when their product is scanned in, so a product
+// that runs low again gets a new one.
+func (s *ShoppingListService) sync(ctx context.Context) error {
+	levels, err := s.alertSvc.lowStockLevels(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialise concurrent syncs so an item is never added twice.
+	if _, err := tx.Exec(ctx, `LOCK TABLE shopping_list_items IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+
+	needed := make([]string, 0, len(levels))
+	for _, l := range levels {
+		needed = append(needed, l.ean)
+		missing := max(l.target-l.quantity, 1)
+
+		tag, err := tx.Exec(ctx,
+			`UPDATE shopping_list_items SET quantity = $2
+			 WHERE ean = $1 AND automatic AND NOT checked`,
+			l.ean, missing,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			continue
+		}
+		_, err = tx.Exec(ctx,
+			`INSERT INTO shopping_list_items (ean, quantity, automatic)
+			 SELECT $1, $2, TRUE
+			 WHERE NOT EXISTS (SELECT 1 FROM shopping_list_items WHERE ean = $1)`,
+			l.ean, missing,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx,
+		`DELETE FROM shopping_list_items
+		 WHERE automatic AND NOT checked AND NOT (ean = ANY($1))`,
+		needed,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// checkOffShoppingItems ticks off the open manual list items for ean within
+// tx and removes its automatic items, checked or not, which sync adds again
+// when the product runs low. It is called when the product is scanned into
+// inventory.
+func checkOffShoppingItems(ctx context.Context, tx pgx.Tx, ean string) error {
+	_, err := tx.Exec(ctx,
+		`DELETE FROM shopping_list_items WHERE ean = $1 AND automatic`, ean,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE shopping_list_items SET checked = TRUE
+		 WHERE ean = $1 AND NOT checked`, ean,
+	)
+	return err
+}
+
+func (s *ShoppingListService) getByID(ctx context.Context, id int) (*model.ShoppingListItem, error) {
+	return scanShoppingItem(s.db.QueryRow(ctx, shoppingItemSelect+`
+		WHERE s.id = $1`, id,
+	))
+}
+
+// scanShoppingItem decodes one row selected with shoppingItemSelect.
+func scanShoppingItem(row pgx.Row) (*model.ShoppingListItem, error) {
+	var item model.ShoppingListItem
+	err := row.Scan(
+		&item.ID, &item.EAN, &item.Name, &item.Quantity,
+		&item.Checked, &item.Automatic, &item.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"foodinventory/internal/model"
+)
+
+func TestShoppingListRestock(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	products := NewProductService(pool, nil, 0, nil)
+	inventory := NewInventoryService(pool, products, 0)
+	shopping := NewShoppingListService(pool, NewAlertService(pool), products)
+
+	ean := createProduct(t, products, "Milk")
+	if _, err := products.Watch(ctx, ean, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// automatic returns the automatic items of the product.
+	automatic := func() []model.ShoppingListItem {
+		t.Helper()
+		items, err := shopping.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []model.ShoppingListItem
+		for _, item := range items {
+			if item.EAN != nil && *item.EAN == ean && item.Automatic {
+				found = append(found, item)
+			}
+		}
+		return found
+	}
+
+	// Low: out of stock, so an item for the target of three is added.
+	if items := automatic(); len(items) != 1 || items[0].Checked || items[0].Quantity != 3 {
+		t.Fatalf("out of stock: automatic items = %+v, want one open item of 3", items)
+	}
+
+	// Scanned in: the item is done.
+	three := 3
+	if _, _, err := inventory.Add(ctx, model.AddProductRequest{EAN: ean, Quantity: &three}); err != nil {
+		t.Fatal(err)
+	}
+	if items := automatic(); len(items) != 0 {
+		t.Fatalf("restocked: automatic items = %+v, want none", items)
+	}
+
+	// Low again: a new item is added.
+	two := 2
+	if _, err := inventory.Remove(ctx, ean, model.RemoveProductRequest{Quantity: &two}); err != nil {
+		t.Fatal(err)
+	}
+	if items := automatic(); len(items) != 1 || items[0].Checked || items[0].Quantity != 2 {
+		t.Fatalf("low again: automatic items = %+v, want one open item of 2", items)
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"foodinventory/internal/model"
+)
+
+// Sentinel errors mapped to HTTP status codes in the handler layer.
+var (
+	ErrOperationNotFound = errors.New("operation not found")
+	ErrNothingToUndo     = errors.New("no operation to undo")
+	ErrNotUndoable       = errors.New("operation cannot be undone")
+	ErrAlreadyUndone     = errors.New("operation was already undone")
+	ErrUndoExpired       = errors.New("undo window has expired")
+	ErrUndoConflict      = errors.New("stock changed since the operation")
+)
+
+// undoableSources lists the operations that can be reverted.
+var undoableSources = []string{
+	string(model.EventAdd), string(model.EventRemove), string(model.EventMove),
+	string(model.EventSet), string(model.EventOpen),
+}
+
+// UndoLast reverts the most recent operation that can still be undone: an
+// add, remove, move, recount or opening within the undo window that has not
+// been reverted yet.
+// Repeated calls walk further back in time.
+func (s *InventoryService) UndoLast(ctx context.Context) (*model.UndoResult, error) {
+	var opID int64
+	err := s.db.QueryRow(ctx,
+		`SELECT e.operation_id
+		 FROM inventory_events e
+		 WHERE e.source = ANY($1)
+		   AND e.occurred_at >= $2
+		   AND NOT EXISTS (
+		       SELECT 1 FROM inventory_events u
+		       WHERE u.reverts_operation_id = e.operation_id)
+		 ORDER BY e.operation_id DESC
+		 LIMIT 1`,
+		undoableSources, time.Now().Add(-s.undoWindow),
+	).Scan(&opID)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNothingToUndo
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Undo(ctx, opID)
+}
+
+// Undo exactly reverts operation opID in a single transaction: added units
+// are taken out of the lots they went into, removed units are put back, and
+// lots deleted at quantity 0 are recreated with their original expiry date,
+// location and threshold. The revert is appended to the ledger as an undo
+// operation.
+func (s *InventoryService) Undo(ctx context.Context, opID int64) (*model.UndoResult, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the events serialises concurrent undos of the same operation.
+	rows, err := tx.Query(ctx,
+		`SELECT occurred_at, source, delta, amount_delta,
+		        ean, COALESCE(inventory_id, 0), TO_CHAR(expiry_date, 'YYYY-MM-DD'), batch,
+		        location_id, COALESCE(low_stock_threshold, 1),
+		        TO_CHAR(opened_at, 'YYYY-MM-DD')
+		 FROM inventory_events
+		 WHERE operation_id = $1
+		 ORDER BY id DESC
+		 FOR UPDATE`, opID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	type event struct {
+		occurredAt  time.Time
+		source      model.EventSource
+		delta       int
+		amountDelta float64
+		lot         lot
+	}
+	var events []event
+	for rows.Next() {
+		var e event
+		if err := rows.Scan(
+			&e.occurredAt, &e.source, &e.delta, &e.amountDelta,
+			&e.lot.ean, &e.lot.id, &e.lot.expiryDate, &e.lot.batch, &e.lot.locationID,
+			&e.lot.threshold,
+			&e.lot.openedAt,
+		); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 {
+		return nil, ErrOperationNotFound
+	}
+	first := events[0]
+	undoable := false
+	for _, src := range undoableSources {
+		undoable = undoable || string(first.source) == src
+	}
+	if !undoable {
+		return nil, ErrNotUndoable
+	}
+	if time.Since(first.occurredAt) > s.undoWindow {
+		return nil, ErrUndoExpired
+	}
+	var undone bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM inventory_events WHERE reverts_operation_id = $1)`, opID,
+	).Scan(&undone)
+	if err != nil {
+		return nil, err
+	}
+	if undone {
+		return nil, ErrAlreadyUndone
+	}
+
+	undoID, err := newOperationID(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if e.lot.id == 0 {
+			continue
+		}
+		current, err := s.revertEvent(ctx, tx, e.lot, e.delta, e.amountDelta)
+		if err != nil {
+			return nil, err
+		}
+		err = recordEvent(ctx, tx, stockEvent{
+			operationID: undoID,
+			lot:         current,
+			delta:       -e.delta,
+			amountDelta: -e.amountDelta,
+			source:      model.EventUndo,
+			reverts:     &opID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	entries, err := s.listByEAN(ctx, first.lot.ean)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UndoResult{OperationID: opID, EAN: first.lot.ean, Entries: entries}, nil
+}
+
+// revertEvent applies -delta packages and -amountDelta to the lot snapshot
+// l. Returns the lot as it was before the revert, for the ledger. Fails with
+// ErrUndoConflict when the units to take back are no longer there, or the
+// lot's location was deleted or its product merged into another one.
+func (s *InventoryService) revertEvent(
+	ctx context.Context, tx pgx.Tx, l lot, delta int, amountDelta float64,
+) (lot, error) {
+	current, err := scanLot(tx.QueryRow(ctx,
+		`SELECT `+lotColumns+` FROM inventory WHERE id = $1 FOR UPDATE`, l.id,
+	))
+	exists := err == nil
+	if err != nil && err != pgx.ErrNoRows {
+		return lot{}, err
+	}
+
+	if amountDelta > 0 {
+		// Added units: take them back out of the lot.
+		if !exists || current.quantity < delta || current.amount < amountDelta-amountEpsilon {
+			return lot{}, ErrUndoConflict
+		}
+		return current, changeLot(ctx, tx, current, -delta, -amountDelta)
+	}
+
+	// Removed units: put them back, recreating the lot if it was deleted.
+	if !exists {
+		if err := checkLocation(ctx, tx, l.locationID); err != nil {
+			if errors.Is(err, ErrLocationNotFound) {
+				return lot{}, ErrUndoConflict
+			}
+			return lot{}, err
+		}
+		if _, _, err := productMeasure(ctx, tx, l.ean); err != nil {
+			// The product was merged into another one.
+			if errors.Is(err, ErrProductNotFound) {
+				return lot{}, ErrUndoConflict
+			}
+			return lot{}, err
+		}
+		current = l
+		current.quantity = 0
+	}
+	return current, restoreLot(ctx, tx, current, -delta, -amountDelta)
+}
@@ -4,30 +4,72 @@ export interface Product {
   category: string | null;
   image_url: string | null;
   resolved: boolean;
+  min_quantity: number | null;
+  target_quantity: number | null;
+  unit: 'pcs' | 'g' | 'ml';
+  package_size: number | null;
+  low_stock_amount: number | null;
+  days_after_opening: number | null;
+  aliases: string[];
+  group_id: number | null;
+  contains_ean: string | null;
+  contains_quantity: number | null;
+  contained_in: string[];
+  lookup_status: 'found' | 'not_found' | 'failed' | 'rate_limited' | null;
+  looked_up_at: string | null;
+}
+
+export interface Scan {
+  ean: string;
+  format?: 'ean_8' | 'upc_e' | 'upc_a' | 'ean_13';
+}
+
+export interface ProductGroup {
+  id: number;
+  name: string;
+  min_quantity: number;
+  quantity: number;
+  members: Product[];
+}
+
+export interface Location {
+  id: number;
+  name: string;
 }
 
 export interface InventoryEntry {
   id: number;
   product: Product;
   quantity: number;
+  amount: number;
   expiry_date: string | null;
+  batch: string | null;
   low_stock_threshold: number;
+  opened_at: string | null;
+  opened_use_by: string | null;
+  location: Location | null;
+  consumption_rate: number | null;
+  depletion_date: string | null;
 }
 
 export interface Alert {
-  type: 'low_stock' | 'expiry_soon';
+  type: 'low_stock' | 'out_of_stock' | 'depletion_soon' | 'expiry_soon' | 'opened_expiring';
   ean: string;
+  inventory_id?: number;
+  group_id?: number;
   product_name: string;
   detail: string;
 }
 
 export interface Settings {
   expiry_warning_days: number;
+  depletion_horizon_days: number;
 }
 
 export interface APIError {
   code: string;
   message: string;
+  details?: Record<string, unknown>;
 }
 
 async function request<T>(path: string, init?: RequestInit): Promise<T> {
@@ -45,21 +87,53 @@ export const api = {
   inventory: {
     list: () =>
       request<InventoryEntry[]>('/api/inventory'),
-    add: (ean: string, expiry_date?: string) =>
+    add: (ean: string, expiry_date?: string, format?: Scan['format']) =>
       request<InventoryEntry>('/api/inventory', {
         method: 'POST',
-        body: JSON.stringify({ ean, expiry_date: expiry_date ?? null })
+        body: JSON.stringify({ ean, expiry_date: expiry_date ?? null, format: format ?? null })
       }),
-    remove: (ean: string) =>
-      request<InventoryEntry | null>(`/api/inventory/${ean}`, { method: 'DELETE' })
+    remove: (ean: string, format?: Scan['format']) =>
+      request<InventoryEntry | null>(
+        `/api/inventory/${ean}` + (format ? `?format=${format}` : ''),
+        { method: 'DELETE' }
+      )
   },
   products: {
+    get: (ean: string) => request<Product>(`/api/products/${ean}`),
+    create: (data: {
+      name: string;
+      category?: string | null;
+      unit?: 'pcs' | 'g' | 'kg' | 'ml' | 'l';
+      package_size?: number | null;
+      days_after_opening?: number | null;
+    }) =>
+      request<Product>('/api/products', {
+        method: 'POST',
+        body: JSON.stringify(data)
+      }),
     update: (ean: string, data: { name: string; category?: string | null }) =>
       request<void>(`/api/products/${ean}`, {
         method: 'PATCH',
         body: JSON.stringify(data)
+      }),
+    setContents: (ean: string, contained: string, quantity: number) =>
+      request<Product>(`/api/products/${ean}/contents`, {
+        method: 'PUT',
+        body: JSON.stringify({ ean: contained, quantity })
       })
   },
+  groups: {
+    list: () => request<ProductGroup[]>('/api/groups'),
+    create: (name: string, min_quantity: number) =>
+      request<ProductGroup>('/api/groups', {
+        method: 'POST',
+        body: JSON.stringify({ name, min_quantity })
+      }),
+    addMember: (id: number, ean: string) =>
+      request<ProductGroup>(`/api/groups/${id}/members/${ean}`, { method: 'PUT' }),
+    removeMember: (id: number, ean: string) =>
+      request<void>(`/api/groups/${id}/members/${ean}`, { method: 'DELETE' })
+  },
   alerts: {
     list: () => request<Alert[]>('/api/alerts')
   },